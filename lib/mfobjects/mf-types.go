@@ -1,11 +1,18 @@
 package mfobjects
 
 import (
+	"strconv"
 	"time"
 
 	"willnorris.com/go/microformats"
 )
 
+// Values of the post-status property.
+const (
+	PostStatusPublished = "published"
+	PostStatusDraft     = "draft"
+)
+
 type MF2HEntry struct {
 	Name        string    `yaml:"name,omitempty"`
 	Summary     string    `yaml:"summary,omitempty"`
@@ -16,11 +23,17 @@ type MF2HEntry struct {
 	Category    []string  `yaml:"content_tags,omitempty"`
 	Url         string    `yaml:"-"`
 	Photos      MF2Photos `yaml:"photos,omitempty"`
+	Videos      []string  `yaml:"videos,omitempty"`
+	Audio       []string  `yaml:"audio,omitempty"`
 	InReplyTo   MF2HCite  `yaml:"in_reply_to,omitempty"`
 	RSVP        string    `yaml:"rsvp,omitempty"`
 	LikeOf      MF2HCite  `yaml:"like_of,omitempty"`
 	RepostOf    MF2HCite  `yaml:"repost_of,omitempty"`
+	BookmarkOf  MF2HCite  `yaml:"bookmark_of,omitempty"`
+	Checkin     MF2HVenue `yaml:"checkin,omitempty"`
 	Syndication []string  `yaml:"syndication,omitempty"`
+	// PostStatus is "draft" for drafts, an empty string or "published" for published posts
+	PostStatus string `yaml:"post_status,omitempty"`
 }
 
 type MF2HCite struct {
@@ -38,6 +51,21 @@ type MF2HCard struct {
 	Name string `yaml:"name,omitempty"`
 }
 
+// MF2HVenue is the h-card of a place, e.g. the location of a checkin.
+type MF2HVenue struct {
+	Name      string  `yaml:"name,omitempty"`
+	Url       string  `yaml:"url,omitempty"`
+	Latitude  float64 `yaml:"latitude,omitempty"`
+	Longitude float64 `yaml:"longitude,omitempty"`
+	Locality  string  `yaml:"locality,omitempty"`
+	Region    string  `yaml:"region,omitempty"`
+	Country   string  `yaml:"country_name,omitempty"`
+}
+
+func (v *MF2HVenue) IsZero() bool {
+	return *v == MF2HVenue{}
+}
+
 type MF2HApp struct {
 	Url          string   `yaml:"url,omitempty"`
 	Name         string   `yaml:"name,omitempty"`
@@ -50,6 +78,8 @@ type MF2HApp struct {
 type MF2Photo struct {
 	Url string `yaml:"url,omitempty"`
 	Alt string `yaml:"alt,omitempty"`
+	// Srcset maps srcset descriptors (e.g. "640w") to urls of alternative sizes of the photo.
+	Srcset map[string]string `yaml:"srcset,omitempty"`
 }
 
 type MF2Photos []MF2Photo
@@ -87,7 +117,19 @@ func (h *MF2HEntry) ToMicroformat() *microformats.Microformat {
 		mf.Properties["url"] = []interface{}{h.Url}
 	}
 	if len(h.Photos) > 0 {
-		mf.Properties["photo"] = []interface{}{h.Photos.ToMicroformat()}
+		mf.Properties["photo"] = h.Photos.ToMicroformat()
+	}
+	if len(h.Videos) > 0 {
+		mf.Properties["video"] = []interface{}{}
+		for _, video := range h.Videos {
+			mf.Properties["video"] = append(mf.Properties["video"], video)
+		}
+	}
+	if len(h.Audio) > 0 {
+		mf.Properties["audio"] = []interface{}{}
+		for _, audio := range h.Audio {
+			mf.Properties["audio"] = append(mf.Properties["audio"], audio)
+		}
 	}
 	if h.InReplyTo.Url != "" {
 		mf.Properties["in-reply-to"] = []interface{}{h.InReplyTo.ToMicroformat()}
@@ -101,16 +143,30 @@ func (h *MF2HEntry) ToMicroformat() *microformats.Microformat {
 	if h.RepostOf.Url != "" {
 		mf.Properties["repost-of"] = []interface{}{h.RepostOf.ToMicroformat()}
 	}
+	if h.BookmarkOf.Url != "" {
+		mf.Properties["bookmark-of"] = []interface{}{h.BookmarkOf.ToMicroformat()}
+	}
+	if !h.Checkin.IsZero() {
+		mf.Properties["checkin"] = []interface{}{h.Checkin.ToMicroformat()}
+	}
 	if len(h.Syndication) > 0 {
 		mf.Properties["syndication"] = []interface{}{}
 		for _, syndication := range h.Syndication {
 			mf.Properties["syndication"] = append(mf.Properties["syndication"], syndication)
 		}
 	}
+	if h.PostStatus != "" {
+		mf.Properties["post-status"] = []interface{}{h.PostStatus}
+	}
 
 	return mf
 }
 
+// IsDraft returns true if the post status of the entry is draft.
+func (h *MF2HEntry) IsDraft() bool {
+	return h.PostStatus == PostStatusDraft
+}
+
 func (h *MF2HCard) ToMicroformat() *microformats.Microformat {
 	mf := &microformats.Microformat{
 		Type:       []string{"h-card"},
@@ -154,10 +210,47 @@ func (h *MF2HCite) ToMicroformat() *microformats.Microformat {
 	return mf
 }
 
-func (p *MF2Photos) ToMicroformat() []map[string]interface{} {
-	slice := make([]map[string]interface{}, 0)
+func (v *MF2HVenue) ToMicroformat() *microformats.Microformat {
+	mf := &microformats.Microformat{
+		Type:       []string{"h-card"},
+		Properties: map[string][]interface{}{},
+	}
+	if v.Name != "" {
+		mf.Properties["name"] = []interface{}{v.Name}
+	}
+	if v.Url != "" {
+		mf.Properties["url"] = []interface{}{v.Url}
+	}
+	if v.Latitude != 0 || v.Longitude != 0 {
+		mf.Properties["latitude"] = []interface{}{strconv.FormatFloat(v.Latitude, 'f', -1, 64)}
+		mf.Properties["longitude"] = []interface{}{strconv.FormatFloat(v.Longitude, 'f', -1, 64)}
+	}
+	if v.Locality != "" {
+		mf.Properties["locality"] = []interface{}{v.Locality}
+	}
+	if v.Region != "" {
+		mf.Properties["region"] = []interface{}{v.Region}
+	}
+	if v.Country != "" {
+		mf.Properties["country-name"] = []interface{}{v.Country}
+	}
+	return mf
+}
+
+// ToMicroformat returns the photo property values. Photos without alt text or srcset
+// are plain urls, all others are objects with a value, alt and srcset property.
+func (p *MF2Photos) ToMicroformat() []interface{} {
+	slice := make([]interface{}, 0, len(*p))
 	for _, photo := range *p {
-		slice = append(slice, map[string]interface{}{"url": photo.Url, "alt": photo.Alt})
+		if photo.Alt == "" && len(photo.Srcset) == 0 {
+			slice = append(slice, photo.Url)
+			continue
+		}
+		mf := map[string]interface{}{"value": photo.Url, "alt": photo.Alt}
+		if len(photo.Srcset) > 0 {
+			mf["srcset"] = photo.Srcset
+		}
+		slice = append(slice, mf)
 	}
 	return slice
 }
@@ -2,6 +2,7 @@ package mfobjects
 
 import (
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,10 +40,16 @@ func GetHEntry(data *microformats.Data) MF2HEntry {
 		Category:    GetStringPropSlice("category", item),
 		Url:         GetStringProp("url", item),
 		Photos:      GetPhotos("photo", item),
+		Videos:      GetStringPropSlice("video", item),
+		Audio:       GetStringPropSlice("audio", item),
 		InReplyTo:   GetHCite("in-reply-to", item),
+		RSVP:        GetStringProp("rsvp", item),
 		LikeOf:      GetHCite("like-of", item),
 		RepostOf:    GetHCite("repost-of", item),
+		BookmarkOf:  GetHCite("bookmark-of", item),
+		Checkin:     GetHVenue("checkin", item),
 		Syndication: GetStringPropSlice("syndication", item),
+		PostStatus:  GetStringProp("post-status", item),
 	}
 }
 
@@ -52,8 +59,8 @@ func GetHCard(name string, item *microformats.Microformat) MF2HCard {
 		if authorStr, ok := author[0].(string); ok {
 			return MF2HCard{Name: authorStr}
 		}
-		authorMf, ok := author[0].(*microformats.Microformat)
-		if ok {
+		authorMf := nestedMicroformat(author[0])
+		if authorMf != nil {
 			return MF2HCard{Name: authorMf.Value}
 		}
 	}
@@ -71,8 +78,8 @@ func GetHCite(name string, item *microformats.Microformat) MF2HCite {
 	if citeString, ok := cite[0].(string); ok {
 		return MF2HCite{Url: citeString}
 	}
-	citeMf, ok := cite[0].(*microformats.Microformat)
-	if ok {
+	citeMf := nestedMicroformat(cite[0])
+	if citeMf != nil {
 		return MF2HCite{
 			Name:        GetStringProp("name", citeMf),
 			Published:   GetTimeProp("published", citeMf),
@@ -87,6 +94,65 @@ func GetHCite(name string, item *microformats.Microformat) MF2HCite {
 	return MF2HCite{}
 }
 
+// GetHVenue returns the h-card of a place. A string value is used as the url of the place.
+func GetHVenue(name string, item *microformats.Microformat) MF2HVenue {
+	venue := item.Properties[name]
+	if len(venue) == 0 {
+		return MF2HVenue{}
+	}
+	if venueString, ok := venue[0].(string); ok {
+		return MF2HVenue{Url: venueString}
+	}
+	venueMf := nestedMicroformat(venue[0])
+	if venueMf == nil {
+		return MF2HVenue{}
+	}
+	return MF2HVenue{
+		Name:      GetStringProp("name", venueMf),
+		Url:       GetStringProp("url", venueMf),
+		Latitude:  GetFloatProp("latitude", venueMf),
+		Longitude: GetFloatProp("longitude", venueMf),
+		Locality:  GetStringProp("locality", venueMf),
+		Region:    GetStringProp("region", venueMf),
+		Country:   GetStringProp("country-name", venueMf),
+	}
+}
+
+// nestedMicroformat returns the microformat of a property value. Besides parsed microformats,
+// the json representation of micropub requests ({"type": [...], "properties": {...}}) is supported.
+// nil is returned if the value is not a microformat.
+func nestedMicroformat(value interface{}) *microformats.Microformat {
+	switch v := value.(type) {
+	case *microformats.Microformat:
+		return v
+	case map[string]interface{}:
+		properties, ok := v["properties"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		mf := &microformats.Microformat{Properties: make(map[string][]interface{}, len(properties))}
+		if types, ok := v["type"].([]interface{}); ok {
+			for _, t := range types {
+				if t, ok := t.(string); ok {
+					mf.Type = append(mf.Type, t)
+				}
+			}
+		}
+		for key, values := range properties {
+			if values, ok := values.([]interface{}); ok {
+				mf.Properties[key] = values
+			}
+		}
+		if name, ok := v["value"].(string); ok {
+			mf.Value = name
+		} else if names := mf.Properties["name"]; len(names) > 0 {
+			mf.Value, _ = names[0].(string)
+		}
+		return mf
+	}
+	return nil
+}
+
 func GetPhotos(name string, item *microformats.Microformat) MF2Photos {
 	propValue, ok := item.Properties[name]
 	if !ok || len(propValue) == 0 {
@@ -99,13 +165,24 @@ func GetPhotos(name string, item *microformats.Microformat) MF2Photos {
 		} else if value, ok := val.(map[string]interface{}); ok {
 			var url string
 			var alt string
-			if value["value"] != nil && value["value"].(string) != "" {
-				url = value["value"].(string)
+			if v, ok := value["value"].(string); ok {
+				url = v
 			}
-			if value["alt"] != nil && value["alt"].(string) != "" {
-				alt = value["alt"].(string)
+			if v, ok := value["alt"].(string); ok {
+				alt = v
 			}
-			slice = append(slice, MF2Photo{Url: url, Alt: alt})
+			var srcset map[string]string
+			if set, ok := value["srcset"].(map[string]interface{}); ok {
+				srcset = make(map[string]string, len(set))
+				for descriptor, src := range set {
+					if src, ok := src.(string); ok {
+						srcset[descriptor] = src
+					}
+				}
+			} else if set, ok := value["srcset"].(map[string]string); ok {
+				srcset = set
+			}
+			slice = append(slice, MF2Photo{Url: url, Alt: alt, Srcset: srcset})
 		}
 	}
 	return slice
@@ -172,6 +249,27 @@ func GetStringPropSlice(name string, item *microformats.Microformat) []string {
 	return slice
 }
 
+// GetFloatProp returns the first value of the property as a number, values can be numbers or strings.
+func GetFloatProp(name string, item *microformats.Microformat) float64 {
+	propValue := item.Properties[name]
+	if len(propValue) == 0 {
+		return 0
+	}
+	switch value := propValue[0].(type) {
+	case float64:
+		return value
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			log.Printf("Could not parse number value %s", value)
+			return 0
+		}
+		return parsed
+	}
+	log.Printf("Did not find number prop %s: %v (%T)", name, propValue[0], propValue[0])
+	return 0
+}
+
 var timeFormats = []string{
 	time.RFC3339,
 	"2006-01-02T15:04:05-07:00",
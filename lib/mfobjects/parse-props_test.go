@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 
@@ -92,3 +93,47 @@ func TestGetHEntry(t *testing.T) {
 		}
 	}
 }
+
+func TestHEntryRoundTrip(t *testing.T) {
+	entry := MF2HEntry{
+		Content:   "Holiday pictures",
+		Published: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		Category:  []string{"travel"},
+		Url:       "https://example.com/posts/1",
+		Photos: MF2Photos{
+			{Url: "https://media.example.com/1.jpg"},
+			{Url: "https://media.example.com/2.jpg", Alt: "A beach"},
+			{Url: "https://media.example.com/3.jpg", Srcset: map[string]string{"320w": "https://media.example.com/3-small.jpg"}},
+		},
+		Videos:      []string{"https://media.example.com/1.mp4"},
+		Audio:       []string{"https://media.example.com/1.mp3", "https://media.example.com/2.ogg"},
+		BookmarkOf:  MF2HCite{Url: "https://example.com/article", Name: "An article"},
+		Checkin:     MF2HVenue{Name: "Cafe", Latitude: 47.3769, Longitude: 8.5417, Locality: "Zurich"},
+		Syndication: []string{},
+	}
+
+	mf := &microformats.Data{Items: []*microformats.Microformat{entry.ToMicroformat()}}
+	got := GetHEntry(mf)
+	deepEqual(got, entry, t, mf, "round trip")
+}
+
+func TestGetHEntryJsonProperties(t *testing.T) {
+	// nested microformats of micropub json requests are not parsed into microformats.Microformat
+	var properties map[string][]interface{}
+	err := json.Unmarshal([]byte(`{
+		"content": ["Coffee"],
+		"in-reply-to": [{"type": ["h-cite"], "properties": {"url": ["https://example.com/"], "name": ["Example"]}}],
+		"checkin": [{"type": ["h-card"], "properties": {"name": ["Cafe"], "latitude": [47.5], "longitude": ["8.5"]}}]
+	}`), &properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := &microformats.Data{Items: []*microformats.Microformat{{Type: []string{"h-entry"}, Properties: properties}}}
+	got := GetHEntry(mf)
+	if got.InReplyTo.Url != "https://example.com/" || got.InReplyTo.Name != "Example" {
+		t.Errorf("unexpected in-reply-to %#v", got.InReplyTo)
+	}
+	if got.Checkin != (MF2HVenue{Name: "Cafe", Latitude: 47.5, Longitude: 8.5}) {
+		t.Errorf("unexpected checkin %#v", got.Checkin)
+	}
+}
@@ -0,0 +1,77 @@
+package mfobjects
+
+import (
+	"strings"
+)
+
+// Post types returned by MF2HEntry.PostType.
+const (
+	PostTypeNote     = "note"
+	PostTypeArticle  = "article"
+	PostTypePhoto    = "photo"
+	PostTypeVideo    = "video"
+	PostTypeAudio    = "audio"
+	PostTypeReply    = "reply"
+	PostTypeLike     = "like"
+	PostTypeRepost   = "repost"
+	PostTypeBookmark = "bookmark"
+	PostTypeCheckin  = "checkin"
+	PostTypeRSVP     = "rsvp"
+)
+
+// PostTypes contains all post types returned by MF2HEntry.PostType.
+var PostTypes = []string{
+	PostTypeNote, PostTypeArticle, PostTypePhoto, PostTypeVideo, PostTypeAudio, PostTypeReply,
+	PostTypeLike, PostTypeRepost, PostTypeBookmark, PostTypeCheckin, PostTypeRSVP,
+}
+
+// PostType returns the type of the entry according to the post type discovery algorithm
+// (https://www.w3.org/TR/post-type-discovery/), extended with bookmarks, checkins and audio.
+func (h *MF2HEntry) PostType() string {
+	switch {
+	case isValidRSVP(h.RSVP):
+		return PostTypeRSVP
+	case h.InReplyTo.Url != "":
+		return PostTypeReply
+	case h.RepostOf.Url != "":
+		return PostTypeRepost
+	case h.LikeOf.Url != "":
+		return PostTypeLike
+	case h.BookmarkOf.Url != "":
+		return PostTypeBookmark
+	case !h.Checkin.IsZero():
+		return PostTypeCheckin
+	case len(h.Videos) > 0:
+		return PostTypeVideo
+	case len(h.Audio) > 0:
+		return PostTypeAudio
+	case len(h.Photos) > 0:
+		return PostTypePhoto
+	}
+
+	name := collapseWhitespace(h.Name)
+	if name == "" {
+		return PostTypeNote
+	}
+	content := collapseWhitespace(h.Content)
+	if content == "" {
+		content = collapseWhitespace(h.Summary)
+	}
+	// the name of notes is often the beginning of the content
+	if strings.HasPrefix(content, name) {
+		return PostTypeNote
+	}
+	return PostTypeArticle
+}
+
+func isValidRSVP(rsvp string) bool {
+	switch strings.ToLower(rsvp) {
+	case "yes", "no", "maybe", "interested":
+		return true
+	}
+	return false
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
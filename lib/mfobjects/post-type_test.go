@@ -0,0 +1,33 @@
+package mfobjects
+
+import (
+	"testing"
+)
+
+func TestPostType(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry MF2HEntry
+		want  string
+	}{
+		{"note", MF2HEntry{Content: "Hello World"}, PostTypeNote},
+		{"note with name", MF2HEntry{Name: "Hello", Content: "Hello  World"}, PostTypeNote},
+		{"article", MF2HEntry{Name: "A Title", Content: "Some content"}, PostTypeArticle},
+		{"article with summary", MF2HEntry{Name: "A Title", Summary: "Summary"}, PostTypeArticle},
+		{"photo", MF2HEntry{Content: "Look", Photos: MF2Photos{{Url: "https://example.com/1.jpg"}}}, PostTypePhoto},
+		{"video", MF2HEntry{Videos: []string{"https://example.com/1.mp4"}, Photos: MF2Photos{{Url: "https://example.com/1.jpg"}}}, PostTypeVideo},
+		{"audio", MF2HEntry{Audio: []string{"https://example.com/1.mp3"}}, PostTypeAudio},
+		{"reply", MF2HEntry{Content: "Yes", InReplyTo: MF2HCite{Url: "https://example.com/"}}, PostTypeReply},
+		{"rsvp", MF2HEntry{RSVP: "yes", InReplyTo: MF2HCite{Url: "https://example.com/event"}}, PostTypeRSVP},
+		{"invalid rsvp", MF2HEntry{RSVP: "perhaps", InReplyTo: MF2HCite{Url: "https://example.com/event"}}, PostTypeReply},
+		{"like", MF2HEntry{LikeOf: MF2HCite{Url: "https://example.com/"}}, PostTypeLike},
+		{"repost", MF2HEntry{RepostOf: MF2HCite{Url: "https://example.com/"}, LikeOf: MF2HCite{Url: "https://example.com/"}}, PostTypeRepost},
+		{"bookmark", MF2HEntry{Name: "Link", BookmarkOf: MF2HCite{Url: "https://example.com/"}}, PostTypeBookmark},
+		{"checkin", MF2HEntry{Checkin: MF2HVenue{Name: "Cafe", Latitude: 47.3, Longitude: 8.5}}, PostTypeCheckin},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.PostType(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
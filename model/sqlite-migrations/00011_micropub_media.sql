@@ -0,0 +1,15 @@
+-- +goose Up
+
+CREATE TABLE micropub_media (
+  url TEXT NOT NULL PRIMARY KEY,
+  content_type TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  client_id TEXT NOT NULL,
+  ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX micropub_media_ts ON micropub_media (ts);
+
+-- +goose Down
+
+DROP TABLE micropub_media;
@@ -0,0 +1,15 @@
+-- +goose Up
+
+CREATE TABLE micropub_scheduled (
+  id TEXT NOT NULL PRIMARY KEY,
+  publish_at TIMESTAMP NOT NULL,
+  post TEXT NOT NULL,
+  syndicate_to TEXT NOT NULL,
+  ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX micropub_scheduled_publish_at ON micropub_scheduled (publish_at);
+
+-- +goose Down
+
+DROP TABLE micropub_scheduled;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE indieauth_tokens (
+  jti TEXT NOT NULL PRIMARY KEY,
+  client_id TEXT NOT NULL,
+  scope TEXT NOT NULL,
+  issued_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  last_used TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+CREATE INDEX indieauth_tokens_client_id ON indieauth_tokens (client_id);
+
+-- +goose Down
+
+DROP TABLE indieauth_tokens;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE micropub_scheduled ADD COLUMN claimed TIMESTAMP;
+ALTER TABLE micropub_scheduled ADD COLUMN location TEXT;
+
+-- +goose Down
+
+ALTER TABLE micropub_scheduled DROP COLUMN location;
+ALTER TABLE micropub_scheduled DROP COLUMN claimed;
@@ -0,0 +1,103 @@
+package indieauth
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	_ "embed"
+)
+
+//go:embed admin-tokens-section.tmpl
+var tokensTemplate string
+
+type adminTokensSection struct {
+	store    Store
+	template *template.Template
+}
+
+type clientTokensView struct {
+	ClientId string
+	Tokens   []tokenView
+}
+
+type tokenView struct {
+	Jti       string
+	Scope     string
+	IssuedAt  string
+	ExpiresAt string
+	LastUsed  string
+}
+
+func newAdminTokensSection(store Store) *adminTokensSection {
+	return &adminTokensSection{
+		store: store,
+	}
+}
+
+func (ui *adminTokensSection) Init() error {
+	ui.template = template.Must(template.New("tokens").Parse(tokensTemplate))
+	return nil
+}
+
+func (ui *adminTokensSection) Name() string {
+	return "IndieAuth Tokens"
+}
+
+func (ui *adminTokensSection) HTML() (string, error) {
+	tokens, err := ui.store.ListTokens()
+	if err != nil {
+		return "", fmt.Errorf("unable to get tokens: %w", err)
+	}
+
+	// the tokens are ordered by client id
+	clients := make([]clientTokensView, 0)
+	for _, token := range tokens {
+		if len(clients) == 0 || clients[len(clients)-1].ClientId != token.clientId {
+			clients = append(clients, clientTokensView{ClientId: token.clientId})
+		}
+		lastUsed := "never"
+		if !token.lastUsed.IsZero() {
+			lastUsed = token.lastUsed.Local().Format("2006-01-02 15:04 MST")
+		}
+		client := &clients[len(clients)-1]
+		client.Tokens = append(client.Tokens, tokenView{
+			Jti:       token.jti,
+			Scope:     token.scope,
+			IssuedAt:  token.issuedAt.Local().Format("2006-01-02 15:04 MST"),
+			ExpiresAt: token.expiresAt.Local().Format("2006-01-02 15:04 MST"),
+			LastUsed:  lastUsed,
+		})
+	}
+
+	var buf bytes.Buffer
+	err = ui.template.Execute(&buf, map[string]interface{}{"Clients": clients})
+	if err != nil {
+		return "", fmt.Errorf("unable to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (ui *adminTokensSection) RegisterRoutes(group *gin.RouterGroup) error {
+	group.POST("/indieauth/tokens/revoke", ui.handleRevoke)
+	return nil
+}
+
+func (ui *adminTokensSection) handleRevoke(c *gin.Context) {
+	jti := c.PostForm("jti")
+	if jti == "" {
+		c.JSON(400, gin.H{"error": "missing jti"})
+		return
+	}
+
+	err := ui.store.RevokeToken(jti)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin")
+}
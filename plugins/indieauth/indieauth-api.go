@@ -1,6 +1,7 @@
 package indieauth
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -18,6 +19,13 @@ import (
 //go:embed authorize.tmpl
 var authorizeTemplate string
 
+// ErrInsufficientScope is returned by VerifyToken if the token is valid but lacks one of the required scopes.
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// ErrTokenRevoked is returned by VerifyToken for a token that has been revoked, or whose id has not been recorded
+// when it was issued.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 // TODO: find proper names for the plugin instances
 type IndieAuthApiModule struct {
 	store               Store
@@ -49,6 +57,16 @@ func (m *IndieAuthApiModule) Name() string {
 	return "indieauth"
 }
 
+// Me returns the canonical profile url of the user that tokens are issued for.
+func (m *IndieAuthApiModule) Me() string {
+	return m.profileCanonicalUrl
+}
+
+// BaseUrl returns the url indiego is running on, without a trailing slash.
+func (m *IndieAuthApiModule) BaseUrl() string {
+	return m.baseUrl
+}
+
 func (m *IndieAuthApiModule) Start() error {
 	return nil
 }
@@ -69,6 +87,7 @@ func (m *IndieAuthApiModule) RegisterRoutes(r *gin.Engine) error {
 	m.group.GET("/authorize", m.authorizeEndpoint)
 	m.group.POST("/authorize", m.tokenEndpoint)
 	m.group.POST("/introspection", m.introspectionEndpoint)
+	m.group.POST("/revocation", m.revocationEndpoint)
 	m.group.POST("/login", m.loginEndpoint)
 	return nil
 }
@@ -81,11 +100,13 @@ func (m *IndieAuthApiModule) metadataEndpoint(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"issuer":                           m.baseUrl + "/indieauth",
-		"authorization_endpoint":           m.baseUrl + "/indieauth/authorize",
-		"token_endpoint":                   m.baseUrl + "/indieauth/token",
-		"introspection_endpoint ":          m.baseUrl + "/indieauth/introspection",
-		"code_challenge_methods_supported": challengeNames,
+		"issuer":                                     m.baseUrl + "/indieauth",
+		"authorization_endpoint":                     m.baseUrl + "/indieauth/authorize",
+		"token_endpoint":                             m.baseUrl + "/indieauth/token",
+		"introspection_endpoint ":                    m.baseUrl + "/indieauth/introspection",
+		"code_challenge_methods_supported":           challengeNames,
+		"revocation_endpoint":                        m.baseUrl + "/indieauth/revocation",
+		"revocation_endpoint_auth_methods_supported": []string{"none"},
 	})
 }
 
@@ -159,6 +180,12 @@ func (m *IndieAuthApiModule) authorizeEndpoint(c *gin.Context) {
 }
 
 func (m *IndieAuthApiModule) tokenEndpoint(c *gin.Context) {
+	if c.Request.FormValue("action") == "revoke" {
+		// legacy revocation request of the indieauth spec
+		m.revocationEndpoint(c)
+		return
+	}
+
 	grantType := c.Request.FormValue("grant_type")
 	code := c.Request.FormValue("code")
 	clientId := c.Request.FormValue("client_id")
@@ -213,6 +240,7 @@ func (m *IndieAuthApiModule) tokenEndpoint(c *gin.Context) {
 			"aud": accessToken.clientId,
 			"iat": accessToken.issuedAt.Unix(),
 			"exp": accessToken.expiresAt.Unix(),
+			"jti": accessToken.jti,
 			m.baseUrl: map[string]interface{}{
 				"scope": accessToken.scope,
 			},
@@ -249,14 +277,9 @@ func (m *IndieAuthApiModule) introspectionEndpoint(c *gin.Context) {
 		c.AbortWithError(400, fmt.Errorf("no token provided"))
 		return
 	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.jwtSecret), nil
-	})
+	claims, err := m.activeClaims(tokenString)
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid && err == nil {
+	if err == nil {
 		c.JSON(200, gin.H{
 			"active":    true,
 			"me":        claims["sub"],
@@ -270,47 +293,114 @@ func (m *IndieAuthApiModule) introspectionEndpoint(c *gin.Context) {
 	}
 }
 
-func (m *IndieAuthApiModule) VerifyToken(tokenString string, minimalScopes []string) (ScopeCheck, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// revocationEndpoint revokes the token as defined in RFC 7009. The response is the same whether
+// the token was valid or not, so a client can not probe for tokens.
+func (m *IndieAuthApiModule) revocationEndpoint(c *gin.Context) {
+	tokenString := c.Request.FormValue("token")
+	if tokenString == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid_request", "error_description": "no token provided"})
+		return
+	}
+
+	token, err := m.parseToken(tokenString)
+	if err == nil && token.Valid {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if jti, ok := claims["jti"].(string); ok && jti != "" {
+			if err := m.store.RevokeToken(jti); err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+			m.logger.Printf("revoked token %s of %v", jti, claims["aud"])
+		}
+	}
+	c.Status(200)
+}
+
+func (m *IndieAuthApiModule) parseToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(m.jwtSecret), nil
 	})
+}
+
+// validClaims returns the claims of the token if it is correctly signed and has not expired.
+func (m *IndieAuthApiModule) validClaims(tokenString string) (jwt.MapClaims, error) {
+	token, err := m.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
 
+// activeClaims returns the claims of the token if it is valid and has not been revoked, and records its use.
+func (m *IndieAuthApiModule) activeClaims(tokenString string) (jwt.MapClaims, error) {
+	claims, err := m.validClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	// tokens issued before tokens were recorded have no id, they stay valid until they expire
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return claims, nil
+	}
+	active, err := m.store.UseToken(jti)
 	if err != nil {
 		return nil, err
 	}
+	if !active {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && err == nil && token.Valid {
-		// check scopes
-		scopes := strings.Split(claims[m.baseUrl].(map[string]interface{})["scope"].(string), " ")
-		for _, minimalScope := range minimalScopes {
-			found := false
-			for _, scope := range scopes {
-				if scope == minimalScope {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, fmt.Errorf("missing scope %s", minimalScope)
+// ClientId returns the client id of the application the token was issued to.
+// It does not check whether the token has been revoked, that is left to VerifyToken.
+func (m *IndieAuthApiModule) ClientId(tokenString string) (string, error) {
+	claims, err := m.validClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+	clientId, _ := claims["aud"].(string)
+	return clientId, nil
+}
+
+func (m *IndieAuthApiModule) VerifyToken(tokenString string, minimalScopes []string) (ScopeCheck, error) {
+	claims, err := m.activeClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// check scopes
+	scopes := strings.Split(claims[m.baseUrl].(map[string]interface{})["scope"].(string), " ")
+	for _, minimalScope := range minimalScopes {
+		found := false
+		for _, scope := range scopes {
+			if scope == minimalScope {
+				found = true
+				break
 			}
 		}
+		if !found {
+			return nil, fmt.Errorf("%w: missing scope %s", ErrInsufficientScope, minimalScope)
+		}
+	}
 
-		scopeChecker := func(scope string) bool {
-			for _, s := range scopes {
-				if s == scope {
-					return true
-				}
+	scopeChecker := func(scope string) bool {
+		for _, s := range scopes {
+			if s == scope {
+				return true
 			}
-			return false
 		}
-
-		return scopeChecker, nil
-	} else {
-		return nil, fmt.Errorf("invalid token")
+		return false
 	}
+
+	return scopeChecker, nil
 }
 
 func (m *IndieAuthApiModule) loginEndpoint(c *gin.Context) {
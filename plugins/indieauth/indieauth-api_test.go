@@ -0,0 +1,155 @@
+package indieauth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) *sQLiteStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, file := range []string{"00009_indieauth.sql", "00013_indieauth_tokens.sql"} {
+		migration, err := os.ReadFile("../../model/sqlite-migrations/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := strings.Split(strings.Split(string(migration), "-- +goose Down")[0], "-- +goose Up")[1]
+		if _, err := db.Exec(up); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewSQLiteStore(db, 10*time.Minute, time.Hour, log.New(os.Stdout, "[test] ", log.Flags()))
+}
+
+func postForm(r http.Handler, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTokenRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newTestStore(t)
+	api := NewIndieAuthApiModule("https://indiego.example.com", "https://example.com/", "password", "secret", store, http.Client{}, log.New(os.Stdout, "[test] ", log.Flags()))
+	r := gin.New()
+	api.InitGroups(r)
+	api.RegisterRoutes(r)
+
+	verifier := "a-code-verifier"
+	challenge := sha256.Sum256([]byte(verifier))
+
+	// issues a token for an auth code that has been approved for the create scope
+	issueToken := func() string {
+		code, err := newAuthCode("https://app.example.com/callback", "https://app.example.com/", "create", "state", base64.RawURLEncoding.EncodeToString(challenge[:]), "S256", "https://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.StoreAuthCode(code); err != nil {
+			t.Fatal(err)
+		}
+		w := postForm(r, "/indieauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code.code},
+			"client_id":     {"https://app.example.com/"},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {verifier},
+		})
+		var res struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.AccessToken == "" {
+			t.Fatalf("expected a token, got %d: %s", w.Code, w.Body.String())
+		}
+		return res.AccessToken
+	}
+
+	token, other := issueToken(), issueToken()
+	if _, err := api.VerifyToken(token, []string{"create"}); err != nil {
+		t.Fatalf("expected the token to be valid, got %v", err)
+	}
+	// resolving the client id does not count as a use of the token
+	if _, err := api.ClientId(other); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := store.ListTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].lastUsed.IsZero() == tokens[1].lastUsed.IsZero() {
+		t.Fatalf("expected two tokens of which one has been used, got %+v %+v", tokens[0], tokens[1])
+	}
+
+	if w := postForm(r, "/indieauth/revocation", url.Values{"token": {token}}); w.Code != 200 {
+		t.Fatalf("expected revocation to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := api.VerifyToken(token, []string{"create"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected the token to be revoked, got %v", err)
+	}
+	w := postForm(r, "/indieauth/introspection", url.Values{"token": {token}})
+	if !strings.Contains(w.Body.String(), `"active":false`) {
+		t.Errorf("expected introspection to report the token as inactive, got %s", w.Body.String())
+	}
+
+	// legacy revocation with the token endpoint, an unknown token is not an error
+	for _, revoke := range []string{other, "unknown"} {
+		if w := postForm(r, "/indieauth/token", url.Values{"action": {"revoke"}, "token": {revoke}}); w.Code != 200 {
+			t.Errorf("expected revocation to succeed, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if _, err := api.VerifyToken(other, nil); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected the token to be revoked, got %v", err)
+	}
+	if tokens, _ := store.ListTokens(); len(tokens) != 0 {
+		t.Errorf("expected no active tokens, got %d", len(tokens))
+	}
+}
+
+func TestTokenWithoutId(t *testing.T) {
+	store := newTestStore(t)
+	api := NewIndieAuthApiModule("https://indiego.example.com", "https://example.com/", "password", "secret", store, http.Client{}, log.New(os.Stdout, "[test] ", log.Flags()))
+
+	// a token issued before tokens were recorded
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": "https://indiego.example.com",
+		"sub": "https://example.com/",
+		"aud": "https://app.example.com/",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"https://indiego.example.com": map[string]interface{}{
+			"scope": "create",
+		},
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.VerifyToken(token, []string{"create"}); err != nil {
+		t.Errorf("expected the token to be valid until it expires, got %v", err)
+	}
+	clientId, err := api.ClientId(token)
+	if err != nil || clientId != "https://app.example.com/" {
+		t.Errorf("expected the client id of the token, got %q %v", clientId, err)
+	}
+	if _, err := api.VerifyToken(token+"x", nil); err == nil || errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected a token with an invalid signature to be rejected, got %v", err)
+	}
+}
@@ -21,10 +21,14 @@ type AuthCode struct {
 }
 
 type AccessToken struct {
+	// the unique id of the token, stored in the jti claim
+	jti       string
 	scope     string
 	clientId  string
 	issuedAt  time.Time
 	expiresAt time.Time
+	// the zero time if the token has not been used yet
+	lastUsed time.Time
 }
 
 type Store interface {
@@ -32,7 +36,13 @@ type Store interface {
 	GetAuthCode(code string) (*AuthCode, error)
 	DeleteAuthCode(code string) error
 	UpdateScope(code, scope string) error
+	// RedeemAccessToken deletes the auth code and records a new access token issued for it.
 	RedeemAccessToken(authCode string) (*AccessToken, error)
+	// UseToken reports whether the token has been issued and is neither revoked nor expired, and records its use.
+	UseToken(jti string) (bool, error)
+	RevokeToken(jti string) error
+	// ListTokens returns the active tokens ordered by client id and issue date.
+	ListTokens() ([]*AccessToken, error)
 }
 
 type sQLiteStore struct {
@@ -46,13 +56,21 @@ func NewSQLiteStore(db *sql.DB, authCodeValidTime, authTokenValidTime time.Durat
 	return &sQLiteStore{db: db, authCodeValidTime: authCodeValidTime, authTokenValidTime: authTokenValidTime, logger: logger}
 }
 
-func newAuthCode(redirectUri, clientId, scope, state, codeChallenge, codeChallengeMethod, me string) (*AuthCode, error) {
+// randomString returns a url safe random string of 32 bytes.
+func randomString() (string, error) {
 	buffer := make([]byte, 32)
 	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+func newAuthCode(redirectUri, clientId, scope, state, codeChallenge, codeChallengeMethod, me string) (*AuthCode, error) {
+	code, err := randomString()
 	if err != nil {
 		return nil, err
 	}
-	code := base64.RawURLEncoding.EncodeToString(buffer)
 	return &AuthCode{
 		code:                code,
 		redirectUri:         redirectUri,
@@ -112,6 +130,11 @@ func (s *sQLiteStore) UpdateScope(code, scope string) error {
 
 func (s *sQLiteStore) CleanUp() error {
 	_, err := s.db.Exec("DELETE FROM indieauth_auth_codes WHERE ts < ?", time.Now().Add(-s.authCodeValidTime).Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	// expired tokens are rejected by the signature check as well, they are not needed for the revocation list
+	_, err = s.db.Exec("DELETE FROM indieauth_tokens WHERE expires_at < ?", time.Now().UTC().Format(time.RFC3339))
 	return err
 }
 
@@ -144,14 +167,78 @@ func (s *sQLiteStore) RedeemAccessToken(authCode string) (*AccessToken, error) {
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	jti, err := randomString()
+	if err != nil {
 		return nil, err
 	}
-
-	return &AccessToken{
+	now := time.Now().UTC().Truncate(time.Second)
+	token := &AccessToken{
+		jti:       jti,
 		scope:     authCodeObj.scope,
 		clientId:  authCodeObj.clientId,
-		issuedAt:  time.Now(),
-		expiresAt: time.Now().Add(s.authTokenValidTime),
-	}, nil
+		issuedAt:  now,
+		expiresAt: now.Add(s.authTokenValidTime),
+	}
+	_, err = tx.Exec("INSERT INTO indieauth_tokens (jti, client_id, scope, issued_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		token.jti, token.clientId, token.scope, token.issuedAt.Format(time.RFC3339), token.expiresAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (s *sQLiteStore) UseToken(jti string) (bool, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := s.db.Exec("UPDATE indieauth_tokens SET last_used = ? WHERE jti = ? AND revoked_at IS NULL AND expires_at > ?", now, jti, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *sQLiteStore) RevokeToken(jti string) error {
+	_, err := s.db.Exec("UPDATE indieauth_tokens SET revoked_at = ? WHERE jti = ? AND revoked_at IS NULL", time.Now().UTC().Format(time.RFC3339), jti)
+	return err
+}
+
+func (s *sQLiteStore) ListTokens() ([]*AccessToken, error) {
+	rows, err := s.db.Query("SELECT jti, client_id, scope, issued_at, expires_at, last_used FROM indieauth_tokens WHERE revoked_at IS NULL AND expires_at > ? ORDER BY client_id, issued_at DESC",
+		time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*AccessToken, 0)
+	for rows.Next() {
+		var token AccessToken
+		var issuedAt, expiresAt string
+		var lastUsed sql.NullString
+		err := rows.Scan(&token.jti, &token.clientId, &token.scope, &issuedAt, &expiresAt, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		if token.issuedAt, err = time.Parse(time.RFC3339, issuedAt); err != nil {
+			return nil, err
+		}
+		if token.expiresAt, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			if token.lastUsed, err = time.Parse(time.RFC3339, lastUsed.String); err != nil {
+				return nil, err
+			}
+		}
+		tokens = append(tokens, &token)
+	}
+	return tokens, rows.Err()
 }
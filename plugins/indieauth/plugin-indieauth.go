@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/plugins/admin"
 )
 
 type indieAuthPlugin struct {
@@ -55,6 +56,17 @@ func (p *indieAuthPlugin) Load(config config.GlobalConfig, _ interface{}, logger
 		return nil, fmt.Errorf("store module is not of type indieauth.Store: %T", storeInt)
 	}
 
+	adminInt, err := config.GetModule("admin")
+	if err == nil {
+		admin, ok := adminInt.(*admin.AdminModule)
+		if !ok {
+			return nil, fmt.Errorf("admin is not a of type admin.AdminModule: %T", adminInt)
+		}
+		admin.RegisterSection(newAdminTokensSection(store))
+	} else {
+		logger.Printf("admin plugin not loaded, not registering the tokens section")
+	}
+
 	return NewIndieAuthApiModule(
 		p.BaseUrl,
 		p.ProfileCanonicalUrl,
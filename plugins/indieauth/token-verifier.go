@@ -3,3 +3,6 @@ package indieauth
 type ScopeCheck func(scope string) bool
 
 type TokenVerifier func(token string, minimalScopes []string) (ScopeCheck, error)
+
+// ClientIdResolver returns the client id of the application a token was issued to.
+type ClientIdResolver func(token string) (string, error)
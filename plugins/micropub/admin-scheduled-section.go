@@ -0,0 +1,112 @@
+package micropub
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	_ "embed"
+)
+
+//go:embed admin-scheduled-section.tmpl
+var scheduledTemplate string
+
+// the maximum length of the content shown for a scheduled post
+const scheduledPreviewLength = 200
+
+type adminScheduledSection struct {
+	api      *micropubApiModule
+	template *template.Template
+}
+
+type scheduledPostView struct {
+	Id          string
+	PublishAt   string
+	Type        string
+	Name        string
+	Content     string
+	SyndicateTo []string
+}
+
+func newAdminScheduledSection(api *micropubApiModule) *adminScheduledSection {
+	return &adminScheduledSection{
+		api: api,
+	}
+}
+
+func (ui *adminScheduledSection) Init() error {
+	ui.template = template.Must(template.New("scheduled").Parse(scheduledTemplate))
+	return nil
+}
+
+func (ui *adminScheduledSection) Name() string {
+	return "Scheduled Posts"
+}
+
+func (ui *adminScheduledSection) HTML() (string, error) {
+	posts, err := ui.api.queue.List()
+	if err != nil {
+		return "", fmt.Errorf("unable to get scheduled posts: %w", err)
+	}
+
+	views := make([]scheduledPostView, len(posts))
+	for i, post := range posts {
+		entry := post.Post.Entry
+		views[i] = scheduledPostView{
+			Id:          post.Id,
+			PublishAt:   post.PublishAt.Format("2006-01-02 15:04 MST"),
+			Type:        entry.PostType(),
+			Name:        entry.Name,
+			Content:     entry.GetShortContent(scheduledPreviewLength, 3),
+			SyndicateTo: post.SyndicateTo,
+		}
+	}
+
+	var buf bytes.Buffer
+	err = ui.template.Execute(&buf, map[string]interface{}{"Posts": views})
+	if err != nil {
+		return "", fmt.Errorf("unable to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (ui *adminScheduledSection) RegisterRoutes(group *gin.RouterGroup) error {
+	group.POST("/micropub/scheduled/cancel", ui.handleCancel)
+	group.POST("/micropub/scheduled/publish", ui.handlePublishNow)
+	return nil
+}
+
+func (ui *adminScheduledSection) handleCancel(c *gin.Context) {
+	id := c.PostForm("id")
+	if id == "" {
+		c.JSON(400, gin.H{"error": "missing id"})
+		return
+	}
+
+	err := ui.api.cancelScheduled(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin")
+}
+
+func (ui *adminScheduledSection) handlePublishNow(c *gin.Context) {
+	id := c.PostForm("id")
+	if id == "" {
+		c.JSON(400, gin.H{"error": "missing id"})
+		return
+	}
+
+	err := ui.api.publishScheduledNow(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin")
+}
@@ -0,0 +1,193 @@
+package micropub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"willnorris.com/go/microformats"
+)
+
+// the maximum size of a page fetched for the context of a post
+const maxContextPageSize = 2 << 20
+
+// the maximum time spent fetching the context of all cites of a post
+var citeContextTimeout = 10 * time.Second
+
+// the ipv4 ranges of "this network" and of the shared address space of carrier-grade NAT
+var nonPublicNetworks = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// the maximum length and number of lines of the summary of a cite
+const (
+	citeSummaryLength   = 500
+	citeSummaryNewlines = 4
+)
+
+// citeFetcher fetches the pages referenced by replies, likes, reposts and bookmarks
+// and fills in the name, author, published date and summary of the cites.
+type citeFetcher struct {
+	client *http.Client
+	// checkAddress is called with the resolved address of every connection before it is dialed,
+	// it refuses non-public addresses unless it is replaced in tests
+	checkAddress func(address string) error
+	logger       *log.Logger
+}
+
+func newCiteFetcher(client *http.Client, logger *log.Logger) *citeFetcher {
+	f := &citeFetcher{checkAddress: checkPublicAddress, logger: logger}
+	// the address is checked when the connection is dialed, after the dns lookup of the transport,
+	// so it also applies to redirects and a host can not resolve to a different address after a check
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	// a proxy would be dialed instead of the host of the page
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return f.checkAddress(address)
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	fetchClient := *client
+	fetchClient.Transport = transport
+	f.client = &fetchClient
+	return f
+}
+
+// enrichPost fetches the context of all cites of the post. Properties that were sent by the client
+// are not overwritten. Pages that can not be fetched within citeContextTimeout are logged and skipped.
+func (f *citeFetcher) enrichPost(ctx context.Context, post *MicropubPost) {
+	ctx, cancel := context.WithTimeout(ctx, citeContextTimeout)
+	defer cancel()
+	for _, cite := range []*mfobjects.MF2HCite{&post.Entry.InReplyTo, &post.Entry.LikeOf, &post.Entry.RepostOf, &post.Entry.BookmarkOf} {
+		if cite.Url == "" {
+			continue
+		}
+		fetched, err := f.fetchCite(ctx, cite.Url)
+		if err != nil {
+			f.logger.Printf("unable to fetch the context of %s: %v", cite.Url, err)
+			continue
+		}
+		mergeCite(cite, fetched)
+	}
+}
+
+// fetchCite fetches the page and returns the cite of its h-entry, or of its title if the page has no h-entry.
+func (f *citeFetcher) fetchCite(ctx context.Context, citeUrl string) (mfobjects.MF2HCite, error) {
+	u, err := url.Parse(citeUrl)
+	if err != nil {
+		return mfobjects.MF2HCite{}, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return mfobjects.MF2HCite{}, fmt.Errorf("url must have http or https scheme")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, citeUrl, nil)
+	if err != nil {
+		return mfobjects.MF2HCite{}, err
+	}
+	req.Header.Set("Accept", "text/html")
+	res, err := f.client.Do(req)
+	if err != nil {
+		return mfobjects.MF2HCite{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return mfobjects.MF2HCite{}, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(res.Body, maxContextPageSize))
+	if err != nil {
+		return mfobjects.MF2HCite{}, err
+	}
+	// relative urls are resolved against the url after redirects
+	data := microformats.ParseNode(doc.Nodes[0], res.Request.URL)
+	cite := citeFromMicroformats(data)
+	if cite.Name == "" && cite.Summary == "" {
+		cite.Name = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	cite.Accessed = time.Now().UTC()
+	return cite, nil
+}
+
+// citeFromMicroformats returns the cite of the first h-entry of the page. If the h-entry has no author,
+// the first h-card of the page is used.
+func citeFromMicroformats(data *microformats.Data) mfobjects.MF2HCite {
+	entry := mfobjects.GetHEntry(data)
+	cite := mfobjects.MF2HCite{
+		Published: entry.Published,
+		Author:    entry.Author,
+		Summary:   entry.GetShortContent(citeSummaryLength, citeSummaryNewlines),
+	}
+	// the name of notes is usually the content, only articles have a meaningful name
+	if entry.PostType() == mfobjects.PostTypeArticle {
+		cite.Name = entry.Name
+	}
+	if cite.Author.Name == "" {
+		for _, item := range data.Items {
+			for _, itemType := range item.Type {
+				if itemType == "h-card" && cite.Author.Name == "" {
+					cite.Author = mfobjects.MF2HCard{Name: mfobjects.GetStringProp("name", item)}
+				}
+			}
+		}
+	}
+	return cite
+}
+
+// mergeCite fills the empty properties of the cite with the fetched properties.
+func mergeCite(cite *mfobjects.MF2HCite, fetched mfobjects.MF2HCite) {
+	if cite.Name == "" {
+		cite.Name = fetched.Name
+	}
+	if cite.Published.IsZero() {
+		cite.Published = fetched.Published
+	}
+	if cite.Author.Name == "" {
+		cite.Author = fetched.Author
+	}
+	if cite.Summary == "" {
+		cite.Summary = fetched.Summary
+	}
+	if cite.Accessed.IsZero() {
+		cite.Accessed = fetched.Accessed
+	}
+}
+
+// checkPublicAddress returns an error if the ip of the address is a loopback, private, link-local, multicast
+// or shared address, so the micropub endpoint can not be used to probe the internal network.
+func checkPublicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("refusing to connect to %s, it is not an ip address", address)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to connect to the non-public address %s", ip)
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("refusing to connect to the non-public address %s", ip)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package micropub
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+)
+
+const citeTestPage = `<html><head><title>Page title</title></head><body>
+<article class="h-entry">
+	<h1 class="p-name">A long article</h1>
+	<a class="p-author h-card" href="/">Jane Doe</a>
+	<time class="dt-published" datetime="2023-01-02T03:04:05Z">2 January</time>
+	<div class="e-content">The content of the article.</div>
+</article>
+</body></html>`
+
+func TestCiteFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/plain" {
+			w.Write([]byte(`<html><head><title>Just a page</title></head><body>Hello</body></html>`))
+			return
+		}
+		w.Write([]byte(citeTestPage))
+	}))
+	defer server.Close()
+
+	fetcher := newCiteFetcher(server.Client(), log.New(os.Stdout, "[test] ", log.Flags()))
+	fetcher.checkAddress = func(string) error { return nil }
+
+	post := MicropubPost{}
+	post.Entry.InReplyTo = mfobjects.MF2HCite{Url: server.URL + "/article"}
+	post.Entry.LikeOf = mfobjects.MF2HCite{Url: server.URL + "/article", Name: "Sent by the client"}
+	post.Entry.BookmarkOf = mfobjects.MF2HCite{Url: server.URL + "/plain"}
+	fetcher.enrichPost(context.Background(), &post)
+
+	reply := post.Entry.InReplyTo
+	if reply.Name != "A long article" || reply.Author.Name != "Jane Doe" || reply.Summary != "The content of the article." {
+		t.Errorf("unexpected reply context %#v", reply)
+	}
+	if !reply.Published.Equal(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)) || reply.Accessed.IsZero() {
+		t.Errorf("unexpected reply dates %#v", reply)
+	}
+	if post.Entry.LikeOf.Name != "Sent by the client" || post.Entry.LikeOf.Author.Name != "Jane Doe" {
+		t.Errorf("unexpected like context %#v", post.Entry.LikeOf)
+	}
+	if post.Entry.BookmarkOf.Name != "Just a page" {
+		t.Errorf("unexpected bookmark context %#v", post.Entry.BookmarkOf)
+	}
+}
+
+func TestCiteFetcherRefusesPrivateAddresses(t *testing.T) {
+	fetcher := newCiteFetcher(http.DefaultClient, log.New(os.Stdout, "[test] ", log.Flags()))
+	for _, u := range []string{"http://127.0.0.1/", "http://localhost/"} {
+		if _, err := fetcher.fetchCite(context.Background(), u); err == nil {
+			t.Errorf("expected an error for the loopback address %s", u)
+		}
+	}
+}
+
+func TestCiteFetcherRefusesRedirectsToPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected the internal server not to be reached")
+	}))
+	defer internal.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusFound)
+	}))
+	defer public.Close()
+
+	fetcher := newCiteFetcher(public.Client(), log.New(os.Stdout, "[test] ", log.Flags()))
+	// the test servers are both on loopback, only the first one is treated as public
+	fetcher.checkAddress = func(address string) error {
+		if address == public.Listener.Addr().String() {
+			return nil
+		}
+		return checkPublicAddress(address)
+	}
+	if _, err := fetcher.fetchCite(context.Background(), public.URL+"/page"); err == nil {
+		t.Errorf("expected an error for a redirect to a loopback address")
+	}
+}
+
+func TestCheckPublicAddress(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:80", "[::1]:443", "10.0.0.1:80", "192.168.1.1:80", "169.254.169.254:80", "0.0.0.0:80",
+		"0.1.2.3:80", "100.64.0.1:80", "100.127.255.254:80", "224.0.0.1:80", "[ff02::1]:443"} {
+		if err := checkPublicAddress(address); err == nil {
+			t.Errorf("expected %s to be refused", address)
+		}
+	}
+	for _, address := range []string{"93.184.216.34:443", "100.128.0.1:80", "[2606:2800:220:1::]:443"} {
+		if err := checkPublicAddress(address); err != nil {
+			t.Errorf("expected the public address %s to be allowed, got %v", address, err)
+		}
+	}
+}
+
+func TestCiteFetcherDeadline(t *testing.T) {
+	timeout := citeContextTimeout
+	citeContextTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { citeContextTimeout = timeout })
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	fetcher := newCiteFetcher(server.Client(), log.New(os.Stdout, "[test] ", log.Flags()))
+	fetcher.checkAddress = func(string) error { return nil }
+
+	post := MicropubPost{}
+	for _, cite := range []*mfobjects.MF2HCite{&post.Entry.InReplyTo, &post.Entry.LikeOf, &post.Entry.RepostOf, &post.Entry.BookmarkOf} {
+		cite.Url = server.URL + "/slow"
+	}
+	start := time.Now()
+	fetcher.enrichPost(context.Background(), &post)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the enrichment to stop after the deadline, took %s", elapsed)
+	}
+	if !post.Entry.InReplyTo.Accessed.IsZero() {
+		t.Errorf("expected the slow cite to be skipped, got %#v", post.Entry.InReplyTo)
+	}
+}
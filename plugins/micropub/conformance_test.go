@@ -0,0 +1,616 @@
+package micropub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"tiim/go-comment-api/plugins/indieauth"
+
+	"github.com/gin-gonic/gin"
+	"willnorris.com/go/microformats"
+)
+
+// The tests in this file follow the test cases of https://micropub.rocks, the numbers of the
+// subtests are the numbers of the micropub.rocks tests.
+
+// memoryStore keeps the posts in memory, deleted posts are kept so they can be undeleted.
+type memoryStore struct {
+	mu        sync.Mutex
+	urlPrefix string
+	posts     map[string]*MicropubPost
+	// order contains the urls of the posts in the order they were created
+	order []string
+}
+
+func newMemoryStore(urlPrefix string) *memoryStore {
+	return &memoryStore{urlPrefix: urlPrefix, posts: make(map[string]*MicropubPost)}
+}
+
+func (s *memoryStore) Create(post MicropubPost) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := fmt.Sprintf("%s%d", s.urlPrefix, len(s.order)+1)
+	post.Entry.Url = u
+	s.posts[u] = &post
+	s.order = append(s.order, u)
+	return u, nil
+}
+
+func (s *memoryStore) Modify(u, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.get(u)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != postVersion([]byte(post.ToMarkdown())) {
+		return errConflict
+	}
+	return ModifyEntry(post, deleteProps, addProps, replaceProps)
+}
+
+func (s *memoryStore) Delete(u string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.get(u)
+	if err != nil {
+		return err
+	}
+	post.Deleted = true
+	return nil
+}
+
+func (s *memoryStore) UnDelete(u string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[u]
+	if !ok {
+		return errPostNotFound
+	}
+	post.Deleted = false
+	return nil
+}
+
+func (s *memoryStore) Get(u string) (*microformats.Microformat, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, err := s.get(u)
+	if err != nil {
+		return nil, "", err
+	}
+	return post.Entry.ToMicroformat(), postVersion([]byte(post.ToMarkdown())), nil
+}
+
+func (s *memoryStore) List(limit, offset int) ([]*microformats.Microformat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return postsToMicroformats(s.published(), limit, offset), nil
+}
+
+func (s *memoryStore) Categories() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return postCategories(s.published()), nil
+}
+
+func (s *memoryStore) get(u string) (*MicropubPost, error) {
+	post, ok := s.posts[u]
+	if !ok {
+		return nil, errPostNotFound
+	}
+	if post.Deleted {
+		return nil, errPostDeleted
+	}
+	return post, nil
+}
+
+func (s *memoryStore) published() []MicropubPost {
+	posts := make([]MicropubPost, 0, len(s.order))
+	for _, u := range s.order {
+		if !s.posts[u].Deleted {
+			posts = append(posts, *s.posts[u])
+		}
+	}
+	return posts
+}
+
+// memoryMediaStore records the uploaded files and returns an url on media.example.com.
+type memoryMediaStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memoryMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	defer file.Reader.Close()
+	content, err := io.ReadAll(file.Reader)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := fmt.Sprintf("https://media.example.com/%d-%s", len(s.files)+1, file.Name)
+	s.files[u] = content
+	return u, nil
+}
+
+// the access tokens accepted by the stub token verifier and their scopes
+var conformanceTokens = map[string][]string{
+	"full-token":   {"create", "update", "delete", "undelete", "media"},
+	"create-token": {"create"},
+}
+
+func stubVerifyToken(token string, minimalScopes []string) (indieauth.ScopeCheck, error) {
+	scopes, ok := conformanceTokens[token]
+	if !ok {
+		return nil, fmt.Errorf("invalid token")
+	}
+	scopeChecker := func(scope string) bool {
+		for _, s := range scopes {
+			if s == scope {
+				return true
+			}
+		}
+		return false
+	}
+	for _, scope := range minimalScopes {
+		if !scopeChecker(scope) {
+			return nil, fmt.Errorf("%w: missing scope %s", indieauth.ErrInsufficientScope, scope)
+		}
+	}
+	return scopeChecker, nil
+}
+
+type conformanceServer struct {
+	t      *testing.T
+	engine *gin.Engine
+	store  *memoryStore
+	media  *memoryMediaStore
+}
+
+func newConformanceServer(t *testing.T) *conformanceServer {
+	gin.SetMode(gin.TestMode)
+	s := &conformanceServer{
+		t:      t,
+		engine: gin.New(),
+		store:  newMemoryStore("https://example.com/posts/"),
+		media:  &memoryMediaStore{files: make(map[string][]byte)},
+	}
+	api := newMicropubApiModule(s.store, s.media, nil, nil, nil, nil, stubVerifyToken, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+	if err := api.RegisterRoutes(s.engine); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *conformanceServer) do(req *http.Request, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+	return w
+}
+
+func (s *conformanceServer) postForm(token string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(req, token)
+}
+
+func (s *conformanceServer) postJSON(token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return s.do(req, token)
+}
+
+// postMultipart sends the values and files, files maps the field name to the file names.
+func (s *conformanceServer) postMultipart(path, token string, values url.Values, files map[string][]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, vs := range values {
+		for _, v := range vs {
+			if err := writer.WriteField(key, v); err != nil {
+				s.t.Fatal(err)
+			}
+		}
+	}
+	for field, names := range files {
+		for _, name := range names {
+			part, err := writer.CreatePart(map[string][]string{
+				"Content-Disposition": {fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, name)},
+				"Content-Type":        {"image/jpeg"},
+			})
+			if err != nil {
+				s.t.Fatal(err)
+			}
+			part.Write([]byte("image " + name))
+		}
+	}
+	if err := writer.Close(); err != nil {
+		s.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return s.do(req, token)
+}
+
+func (s *conformanceServer) query(token string, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/micropub?"+query, nil)
+	return s.do(req, token)
+}
+
+// created checks that the response is 201 with a location and returns the stored post.
+func (s *conformanceServer) created(w *httptest.ResponseRecorder) *MicropubPost {
+	s.t.Helper()
+	if w.Code != 201 {
+		s.t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	post, ok := s.store.posts[location]
+	if !ok {
+		s.t.Fatalf("no post stored at location %q", location)
+	}
+	return post
+}
+
+// source returns the properties returned by the q=source query.
+func (s *conformanceServer) source(u string, properties ...string) map[string][]interface{} {
+	s.t.Helper()
+	query := url.Values{"q": {"source"}, "url": {u}}
+	for _, property := range properties {
+		query.Add("properties[]", property)
+	}
+	w := s.query("full-token", query.Encode())
+	if w.Code != 200 {
+		s.t.Fatalf("source query failed with %d: %s", w.Code, w.Body.String())
+	}
+	var res struct {
+		Properties map[string][]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		s.t.Fatal(err)
+	}
+	return res.Properties
+}
+
+// createNote creates a note with the content and categories and returns its url.
+func (s *conformanceServer) createNote(content string, categories ...string) string {
+	s.t.Helper()
+	form := url.Values{"h": {"entry"}, "content": {content}, "category[]": categories}
+	w := s.postForm("full-token", form)
+	s.created(w)
+	return w.Header().Get("Location")
+}
+
+func assertEqual(t *testing.T, name string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected %s\n got %#v\nwant %#v", name, got, want)
+	}
+}
+
+func TestConformanceCreate(t *testing.T) {
+	t.Run("100 form-encoded h-entry", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Hello World"}}))
+		assertEqual(t, "content", post.Entry.Content, "Hello World")
+		assertEqual(t, "post type", post.Entry.PostType(), "note")
+	})
+	t.Run("101 form-encoded multiple categories", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Hello"}, "category[]": {"foo", "bar"}}))
+		assertEqual(t, "categories", post.Entry.Category, []string{"foo", "bar"})
+	})
+	t.Run("104 form-encoded photo url", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Photo"}, "photo": {"https://example.com/photo.jpg"}}))
+		if len(post.Entry.Photos) != 1 || post.Entry.Photos[0].Url != "https://example.com/photo.jpg" {
+			t.Errorf("unexpected photos %#v", post.Entry.Photos)
+		}
+	})
+	t.Run("107 form-encoded single category", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Hello"}, "category": {"foo"}}))
+		assertEqual(t, "categories", post.Entry.Category, []string{"foo"})
+	})
+	t.Run("200 JSON h-entry", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": ["Hello World"]}}`))
+		assertEqual(t, "content", post.Entry.Content, "Hello World")
+	})
+	t.Run("201 JSON multiple categories", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": ["Hello"], "category": ["foo", "bar"]}}`))
+		assertEqual(t, "categories", post.Entry.Category, []string{"foo", "bar"})
+	})
+	t.Run("202 JSON html content", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": [{"html": "<p>Hello <b>World</b></p>"}]}}`))
+		assertEqual(t, "content", post.Entry.Content, "<p>Hello <b>World</b></p>")
+	})
+	t.Run("203 JSON photo url", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": ["Photo"], "photo": ["https://example.com/photo.jpg"]}}`))
+		if len(post.Entry.Photos) != 1 || post.Entry.Photos[0].Url != "https://example.com/photo.jpg" {
+			t.Errorf("unexpected photos %#v", post.Entry.Photos)
+		}
+	})
+	t.Run("204 JSON nested object", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {
+			"content": ["Nice post"],
+			"in-reply-to": [{"type": ["h-cite"], "properties": {"url": ["https://example.com/article"], "name": ["An article"]}}]
+		}}`))
+		assertEqual(t, "in-reply-to url", post.Entry.InReplyTo.Url, "https://example.com/article")
+		assertEqual(t, "in-reply-to name", post.Entry.InReplyTo.Name, "An article")
+	})
+	t.Run("205 JSON photo with alt text", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": ["Photo"], "photo": [{"value": "https://example.com/photo.jpg", "alt": "A cat"}]}}`))
+		if len(post.Entry.Photos) != 1 || post.Entry.Photos[0].Alt != "A cat" {
+			t.Errorf("unexpected photos %#v", post.Entry.Photos)
+		}
+	})
+	t.Run("206 JSON multiple photos", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postJSON("full-token", `{"type": ["h-entry"], "properties": {"content": ["Photos"], "photo": ["https://example.com/1.jpg", "https://example.com/2.jpg"]}}`))
+		if len(post.Entry.Photos) != 2 || post.Entry.Photos[1].Url != "https://example.com/2.jpg" {
+			t.Errorf("unexpected photos %#v", post.Entry.Photos)
+		}
+	})
+	t.Run("300 multipart photo", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postMultipart("/micropub", "full-token", url.Values{"h": {"entry"}, "content": {"Photo"}}, map[string][]string{"photo": {"photo.jpg"}}))
+		if len(post.Entry.Photos) != 1 || string(s.media.files[post.Entry.Photos[0].Url]) != "image photo.jpg" {
+			t.Errorf("unexpected photos %#v", post.Entry.Photos)
+		}
+	})
+	t.Run("301 multipart two photos", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postMultipart("/micropub", "full-token", url.Values{"h": {"entry"}, "content": {"Photos"}}, map[string][]string{"photo[]": {"1.jpg", "2.jpg"}}))
+		assertEqual(t, "number of photos", len(post.Entry.Photos), 2)
+	})
+}
+
+func TestConformanceUpdate(t *testing.T) {
+	t.Run("400 replace a property", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": ["Hello Moon"]}}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		assertEqual(t, "content", s.source(u, "content")["content"], []interface{}{"Hello Moon"})
+	})
+	t.Run("401 add a value to an existing property", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello", "foo")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "add": {"category": ["bar"]}}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		assertEqual(t, "categories", s.source(u, "category")["category"], []interface{}{"foo", "bar"})
+	})
+	t.Run("402 add a value to a non-existent property", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "add": {"category": ["foo"]}}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		assertEqual(t, "categories", s.source(u, "category")["category"], []interface{}{"foo"})
+	})
+	t.Run("403 remove a value from a property", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello", "foo", "bar")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "delete": {"category": ["foo"]}}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		assertEqual(t, "categories", s.source(u, "category")["category"], []interface{}{"bar"})
+	})
+	t.Run("404 remove a property", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello", "foo", "bar")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "delete": ["category"]}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		if _, ok := s.source(u)["category"]; ok {
+			t.Errorf("expected the category property to be removed")
+		}
+	})
+	t.Run("405 reject replace that is not an array", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": "Hello Moon"}}`, u))
+		assertEqual(t, "status", w.Code, 400)
+		assertEqual(t, "content", s.source(u, "content")["content"], []interface{}{"Hello"})
+	})
+	t.Run("conditional update with If-Match", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		etag := s.query("full-token", url.Values{"q": {"source"}, "url": {u}}.Encode()).Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("expected q=source to return an ETag")
+		}
+		update := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", etag)
+			return s.do(req, "full-token")
+		}
+		w := update(fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": ["Hello Moon"]}}`, u))
+		assertEqual(t, "status", w.Code, 200)
+		// the etag is stale after the first update
+		w = update(fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": ["Hello Mars"]}}`, u))
+		assertEqual(t, "status", w.Code, 412)
+		assertEqual(t, "content", s.source(u, "content")["content"], []interface{}{"Hello Moon"})
+	})
+	t.Run("update of an unknown post", func(t *testing.T) {
+		s := newConformanceServer(t)
+		w := s.postJSON("full-token", `{"action": "update", "url": "https://example.com/posts/404", "replace": {"content": ["Hello"]}}`)
+		assertEqual(t, "status", w.Code, 400)
+	})
+}
+
+func TestConformanceDelete(t *testing.T) {
+	t.Run("500 delete form-encoded and 502 undelete form-encoded", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postForm("full-token", url.Values{"action": {"delete"}, "url": {u}})
+		assertEqual(t, "delete status", w.Code, 200)
+		assertEqual(t, "source status", s.query("full-token", url.Values{"q": {"source"}, "url": {u}}.Encode()).Code, 410)
+
+		w = s.postForm("full-token", url.Values{"action": {"undelete"}, "url": {u}})
+		assertEqual(t, "undelete status", w.Code, 200)
+		assertEqual(t, "content", s.source(u, "content")["content"], []interface{}{"Hello"})
+	})
+	t.Run("501 delete JSON and 503 undelete JSON", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postJSON("full-token", fmt.Sprintf(`{"action": "delete", "url": %q}`, u))
+		assertEqual(t, "delete status", w.Code, 200)
+		assertEqual(t, "deleted", s.store.posts[u].Deleted, true)
+
+		w = s.postJSON("full-token", fmt.Sprintf(`{"action": "undelete", "url": %q}`, u))
+		assertEqual(t, "undelete status", w.Code, 200)
+		assertEqual(t, "deleted", s.store.posts[u].Deleted, false)
+	})
+}
+
+func TestConformanceQuery(t *testing.T) {
+	t.Run("600 configuration query", func(t *testing.T) {
+		s := newConformanceServer(t)
+		w := s.query("full-token", "q=config")
+		assertEqual(t, "status", w.Code, 200)
+		var res map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"media-endpoint", "syndicate-to", "q"} {
+			if _, ok := res[key]; !ok {
+				t.Errorf("config is missing %s: %v", key, res)
+			}
+		}
+	})
+	t.Run("601 syndication endpoint query", func(t *testing.T) {
+		s := newConformanceServer(t)
+		w := s.query("full-token", "q=syndicate-to")
+		assertEqual(t, "status", w.Code, 200)
+		assertEqual(t, "body", strings.TrimSpace(w.Body.String()), `{"syndicate-to":[]}`)
+	})
+	t.Run("602 source query all properties", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello", "foo")
+		w := s.query("full-token", url.Values{"q": {"source"}, "url": {u}}.Encode())
+		assertEqual(t, "status", w.Code, 200)
+		var res struct {
+			Type       []string                 `json:"type"`
+			Properties map[string][]interface{} `json:"properties"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "type", res.Type, []string{"h-entry"})
+		assertEqual(t, "content", res.Properties["content"], []interface{}{"Hello"})
+		assertEqual(t, "category", res.Properties["category"], []interface{}{"foo"})
+	})
+	t.Run("603 source query specific properties", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello", "foo")
+		properties := s.source(u, "content")
+		assertEqual(t, "properties", properties, map[string][]interface{}{"content": {"Hello"}})
+	})
+	t.Run("source list and categories", func(t *testing.T) {
+		s := newConformanceServer(t)
+		s.createNote("First", "foo")
+		s.createNote("Second", "bar")
+		w := s.query("full-token", "q=source&limit=1")
+		var list struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "number of items", len(list.Items), 1)
+		w = s.query("full-token", "q=category")
+		assertEqual(t, "categories", strings.TrimSpace(w.Body.String()), `{"categories":["bar","foo"]}`)
+	})
+	t.Run("drafts are not listed", func(t *testing.T) {
+		s := newConformanceServer(t)
+		s.createNote("Published", "foo")
+		s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Draft"}, "category": {"secret"}, "post-status": {"draft"}}))
+		w := s.query("full-token", "q=source")
+		var list struct {
+			Items []struct {
+				Properties map[string][]interface{} `json:"properties"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Items) != 1 {
+			t.Fatalf("expected only the published post, got %v", list.Items)
+		}
+		assertEqual(t, "content", list.Items[0].Properties["content"], []interface{}{"Published"})
+		w = s.query("full-token", "q=category")
+		assertEqual(t, "categories", strings.TrimSpace(w.Body.String()), `{"categories":["foo"]}`)
+	})
+}
+
+func TestConformanceMedia(t *testing.T) {
+	for i, name := range []string{"photo.jpg", "photo.png", "photo.gif"} {
+		t.Run(fmt.Sprintf("70%d upload %s", i, name), func(t *testing.T) {
+			s := newConformanceServer(t)
+			w := s.postMultipart("/micropub/media", "full-token", nil, map[string][]string{"file": {name}})
+			assertEqual(t, "status", w.Code, 201)
+			location := w.Header().Get("Location")
+			assertEqual(t, "file", string(s.media.files[location]), "image "+name)
+		})
+	}
+}
+
+func TestConformanceAuthentication(t *testing.T) {
+	t.Run("800 access token in header", func(t *testing.T) {
+		s := newConformanceServer(t)
+		s.created(s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Hello"}}))
+	})
+	t.Run("801 access token in body", func(t *testing.T) {
+		s := newConformanceServer(t)
+		s.created(s.postForm("", url.Values{"h": {"entry"}, "content": {"Hello"}, "access_token": {"full-token"}}))
+	})
+	t.Run("802 access token is not stored", func(t *testing.T) {
+		s := newConformanceServer(t)
+		post := s.created(s.postForm("", url.Values{"h": {"entry"}, "content": {"Hello"}, "access_token": {"full-token"}}))
+		if _, ok := post.RawData.Items[0].Properties["access_token"]; ok {
+			t.Errorf("access token was stored in the form-encoded post")
+		}
+		post = s.created(s.postMultipart("/micropub", "", url.Values{"h": {"entry"}, "content": {"Hello"}, "access_token": {"full-token"}}, nil))
+		if _, ok := post.RawData.Items[0].Properties["access_token"]; ok {
+			t.Errorf("access token was stored in the multipart post")
+		}
+	})
+	t.Run("803 reject unauthenticated requests", func(t *testing.T) {
+		s := newConformanceServer(t)
+		w := s.postForm("", url.Values{"h": {"entry"}, "content": {"Hello"}})
+		assertEqual(t, "status", w.Code, 401)
+		w = s.postForm("invalid-token", url.Values{"h": {"entry"}, "content": {"Hello"}})
+		assertEqual(t, "status", w.Code, 401)
+		assertEqual(t, "stored posts", len(s.store.posts), 0)
+	})
+	t.Run("804 reject insufficient scope", func(t *testing.T) {
+		s := newConformanceServer(t)
+		u := s.createNote("Hello")
+		w := s.postJSON("create-token", fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": ["Hello Moon"]}}`, u))
+		assertEqual(t, "status", w.Code, 401)
+		var res map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "error", res["error"], "insufficient_scope")
+		assertEqual(t, "content", s.source(u, "content")["content"], []interface{}{"Hello"})
+	})
+}
@@ -3,6 +3,7 @@ package micropub
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/gif"
@@ -10,35 +11,71 @@ import (
 	"image/png"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/chai2010/webp"
 )
 
-func jpegEncode(w io.Writer, m image.Image) error {
-	return jpeg.Encode(w, m, &jpeg.Options{Quality: 75})
+const defaultImageQuality = 75
+
+func jpegEncode(w io.Writer, m image.Image, quality int) error {
+	return jpeg.Encode(w, m, &jpeg.Options{Quality: quality})
+}
+
+func pngEncode(w io.Writer, m image.Image, quality int) error {
+	return png.Encode(w, m)
 }
-func gifEncode(w io.Writer, m image.Image) error {
+
+func gifEncode(w io.Writer, m image.Image, quality int) error {
 	return gif.Encode(w, m, &gif.Options{NumColors: 256})
 }
 
-func webpEncode(w io.Writer, m image.Image) error {
-	return webp.Encode(w, m, &webp.Options{Lossless: false, Quality: 75})
+func webpEncode(w io.Writer, m image.Image, quality int) error {
+	return webp.Encode(w, m, &webp.Options{Lossless: false, Quality: float32(quality)})
 }
 
-var encoders = map[string]func(io.Writer, image.Image) error{
+var encoders = map[string]func(w io.Writer, m image.Image, quality int) error{
 	"image/jpeg": jpegEncode,
-	"image/png":  png.Encode,
+	"image/png":  pngEncode,
 	"image/gif":  gifEncode,
 	"image/webp": webpEncode,
 }
 
+// mediaVariantStore is implemented by media stores that save multiple sizes of an image.
+type mediaVariantStore interface {
+	// SaveMediaVariants saves the file and returns the url of the variant to reference in posts
+	// and the urls of all variants keyed by their srcset descriptor (e.g. "640w").
+	// The srcset map is nil if the store did not create variants of the file.
+	SaveMediaVariants(ctx context.Context, file MicropubFile) (string, map[string]string, error)
+}
+
+type imageVariant struct {
+	name      string
+	maxWidth  int
+	maxHeight int
+}
+
 type convertMediaStore struct {
 	childMediaStore mediaStore
 	convertMap      map[string]string
-	logger          *log.Logger
+	// the maximum size of all stored images, 0 means no limit
+	maxWidth  int
+	maxHeight int
+	// the encoding quality per mime type
+	quality map[string]int
+	// the sizes every image is stored in, no variants stores a single image
+	variants []imageVariant
+	// the name of the variant referenced in posts, an empty string selects the largest variant
+	defaultVariant string
+	logger         *log.Logger
 }
 
 func (s convertMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	url, _, err := s.SaveMediaVariants(ctx, file)
+	return url, err
+}
+
+func (s convertMediaStore) SaveMediaVariants(ctx context.Context, file MicropubFile) (string, map[string]string, error) {
 	destType := "-"
 	if ct, ok := s.convertMap[file.ContentType]; ok {
 		destType = ct
@@ -46,24 +83,122 @@ func (s convertMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile
 		destType = ct
 	}
 
-	if destType == "-" {
-		return s.childMediaStore.SaveMediaFiles(ctx, file)
+	// only images are converted, other media like videos and audio is stored as is
+	if !strings.HasPrefix(file.ContentType, "image/") {
+		url, err := s.childMediaStore.SaveMediaFiles(ctx, file)
+		return url, nil, err
+	}
+
+	// images the decoder can not read, like svg or avif, are stored as is even if "*" selects a conversion
+	if _, isImage := encoders[file.ContentType]; !isImage {
+		url, err := s.childMediaStore.SaveMediaFiles(ctx, file)
+		return url, nil, err
 	}
 
-	img, _, err := image.Decode(file.Reader)
+	data, err := io.ReadAll(file.Reader)
+	file.Reader.Close()
 	if err != nil {
-		return "", fmt.Errorf("could not decode image: %v", err)
+		return "", nil, fmt.Errorf("could not read image: %v", err)
+	}
+
+	if destType == "-" {
+		// images that are not converted or resized are stored without their metadata,
+		// unless the orientation has to be applied to the pixels by re-encoding them
+		if !s.resizes() && (file.ContentType != "image/jpeg" || jpegOrientation(data) == 1) {
+			stripped, err := stripMetadata(data, file.ContentType)
+			if err != nil {
+				return "", nil, fmt.Errorf("could not strip image metadata: %v", err)
+			}
+			url, err := s.childMediaStore.SaveMediaFiles(ctx, MicropubFile{
+				Name:        file.Name,
+				ContentType: file.ContentType,
+				Size:        int64(len(stripped)),
+				Reader:      io.NopCloser(bytes.NewReader(stripped)),
+			})
+			return url, nil, err
+		}
+		destType = file.ContentType
 	}
-	if encoder, ok := encoders[destType]; ok {
-		buffer := &bytes.Buffer{}
-		err = encoder(buffer, img)
-		if err != nil {
-			return "", fmt.Errorf("could not encode image: %v", err)
+
+	encoder, ok := encoders[destType]
+	if !ok {
+		return "", nil, fmt.Errorf("could not find encoder for %s, available encoders: %v", destType, encoders)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		// the content type does not match the data, the file is stored as is
+		s.logger.Printf("storing %s unchanged, its format is unknown", file.Name)
+		url, err := s.childMediaStore.SaveMediaFiles(ctx, MicropubFile{
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Size:        int64(len(data)),
+			Reader:      io.NopCloser(bytes.NewReader(data)),
+		})
+		return url, nil, err
+	} else if err != nil {
+		return "", nil, fmt.Errorf("could not decode image: %v", err)
+	}
+	// re-encoding drops all metadata, so the orientation has to be applied to the pixels
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	variants := s.variants
+	if len(variants) == 0 {
+		variants = []imageVariant{{}}
+	}
+
+	var url string
+	largest := -1
+	srcset := make(map[string]string)
+	for _, variant := range variants {
+		resized := resizeImage(img, minLimit(variant.maxWidth, s.maxWidth), minLimit(variant.maxHeight, s.maxHeight))
+		width := resized.Bounds().Dx()
+		descriptor := fmt.Sprintf("%dw", width)
+
+		variantUrl, ok := srcset[descriptor]
+		if !ok {
+			buffer := &bytes.Buffer{}
+			err = encoder(buffer, resized, s.qualityFor(destType))
+			if err != nil {
+				return "", nil, fmt.Errorf("could not encode image: %v", err)
+			}
+			variantUrl, err = s.childMediaStore.SaveMediaFiles(ctx, MicropubFile{
+				Name:        file.Name,
+				ContentType: destType,
+				Size:        int64(buffer.Len()),
+				Reader:      io.NopCloser(buffer),
+			})
+			if err != nil {
+				return "", nil, err
+			}
+			srcset[descriptor] = variantUrl
 		}
-		file.Reader = io.NopCloser(buffer)
-		file.ContentType = destType
-		return s.childMediaStore.SaveMediaFiles(ctx, file)
+
+		if s.defaultVariant != "" {
+			if variant.name == s.defaultVariant {
+				url = variantUrl
+			}
+		} else if width > largest {
+			url = variantUrl
+			largest = width
+		}
+	}
+
+	if len(s.variants) == 0 {
+		return url, nil, nil
 	}
+	return url, srcset, nil
+}
+
+func (s convertMediaStore) resizes() bool {
+	return s.maxWidth > 0 || s.maxHeight > 0 || len(s.variants) > 0
+}
 
-	return "", fmt.Errorf("could not find encoder for %s, available encoders: %v", destType, encoders)
+func (s convertMediaStore) qualityFor(mimeType string) int {
+	if q, ok := s.quality[mimeType]; ok && q > 0 {
+		return q
+	}
+	return defaultImageQuality
 }
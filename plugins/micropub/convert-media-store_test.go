@@ -0,0 +1,211 @@
+package micropub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+type recordingMediaStore struct {
+	files []image.Config
+	types []string
+	data  [][]byte
+}
+
+func (r *recordingMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	defer file.Reader.Close()
+	data, err := io.ReadAll(file.Reader)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) != file.Size {
+		return "", fmt.Errorf("size %d does not match content length %d", file.Size, len(data))
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	r.files = append(r.files, cfg)
+	r.types = append(r.types, file.ContentType)
+	r.data = append(r.data, data)
+	return fmt.Sprintf("https://media.example.com/%d", len(r.files)), nil
+}
+
+// jpegWithOrientation encodes an image and inserts an exif segment with the orientation tag.
+func jpegWithOrientation(t *testing.T, width, height int, orientation uint16) []byte {
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exif := []byte("Exif\x00\x00")
+	exif = append(exif, 'M', 'M', 0, 42, 0, 0, 0, 8) // big endian tiff header, first ifd at offset 8
+	exif = append(exif, 0, 1)                        // one entry
+	exif = append(exif, 0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation>>8), byte(orientation), 0, 0)
+	exif = append(exif, 0, 0, 0, 0) // no next ifd
+	segment := append([]byte{0xFF, 0xE1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+// jpegWithoutExif encodes an image without metadata.
+func jpegWithoutExif(t *testing.T, width, height int) []byte {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvertMediaStoreVariants(t *testing.T) {
+	child := &recordingMediaStore{}
+	store := convertMediaStore{
+		childMediaStore: child,
+		convertMap:      map[string]string{"image/jpeg": "image/png"},
+		maxWidth:        1000,
+		variants: []imageVariant{
+			{name: "thumbnail", maxWidth: 100},
+			{name: "medium", maxHeight: 300},
+			{name: "full"},
+		},
+		defaultVariant: "medium",
+		logger:         log.New(os.Stdout, "[test] ", log.Flags()),
+	}
+
+	// rotated by 90 degrees, the image is 400x1200 after applying the orientation
+	data := jpegWithOrientation(t, 1200, 400, 6)
+	url, srcset, err := store.SaveMediaVariants(context.Background(), MicropubFile{
+		Name:        "photo.jpg",
+		ContentType: "image/jpeg",
+		Size:        int64(len(data)),
+		Reader:      io.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// thumbnail and medium have the same size and are only stored once
+	expected := []image.Config{{Width: 100, Height: 300}, {Width: 400, Height: 1200}}
+	if len(child.files) != len(expected) {
+		t.Fatalf("expected %d stored files, got %d", len(expected), len(child.files))
+	}
+	for i, cfg := range child.files {
+		if cfg.Width != expected[i].Width || cfg.Height != expected[i].Height {
+			t.Errorf("file %d: expected %dx%d, got %dx%d", i, expected[i].Width, expected[i].Height, cfg.Width, cfg.Height)
+		}
+		if child.types[i] != "image/png" {
+			t.Errorf("file %d: expected image/png, got %s", i, child.types[i])
+		}
+	}
+	if url != "https://media.example.com/1" {
+		t.Errorf("expected url of the medium variant, got %s", url)
+	}
+	if srcset["100w"] != "https://media.example.com/1" || srcset["400w"] != "https://media.example.com/2" || len(srcset) != 2 {
+		t.Errorf("unexpected srcset %v", srcset)
+	}
+}
+
+func TestConvertMediaStorePassThrough(t *testing.T) {
+	child := &recordingMediaStore{}
+	store := convertMediaStore{
+		childMediaStore: child,
+		logger:          log.New(os.Stdout, "[test] ", log.Flags()),
+	}
+
+	data := jpegWithOrientation(t, 20, 10, 1)
+	_, srcset, err := store.SaveMediaVariants(context.Background(), MicropubFile{
+		ContentType: "image/jpeg",
+		Size:        int64(len(data)),
+		Reader:      io.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srcset != nil {
+		t.Errorf("expected no srcset, got %v", srcset)
+	}
+	if len(child.files) != 1 || child.files[0].Width != 20 || child.types[0] != "image/jpeg" {
+		t.Errorf("expected the unconverted file to be stored, got %v %v", child.files, child.types)
+	}
+	// the image data is not re-encoded, only the exif segment is removed
+	if bytes.Contains(child.data[0], []byte("Exif")) || !bytes.Equal(child.data[0], jpegWithoutExif(t, 20, 10)) {
+		t.Errorf("expected the exif segment to be removed")
+	}
+
+	// the orientation is applied to the pixels before the exif segment is dropped
+	rotated := jpegWithOrientation(t, 20, 10, 6)
+	_, _, err = store.SaveMediaVariants(context.Background(), MicropubFile{
+		ContentType: "image/jpeg",
+		Size:        int64(len(rotated)),
+		Reader:      io.NopCloser(bytes.NewReader(rotated)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.files[1].Width != 10 || child.files[1].Height != 20 || bytes.Contains(child.data[1], []byte("Exif")) {
+		t.Errorf("expected a rotated image without exif, got %v", child.files[1])
+	}
+}
+
+func TestStripPngMetadata(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// insert a text chunk after the header chunk, its crc is not checked by the decoder
+	text := []byte("Location\x0047.37,8.54")
+	chunk := append([]byte{0, 0, 0, byte(len(text))}, "tEXt"...)
+	chunk = append(append(chunk, text...), 0, 0, 0, 0)
+	withText := append(append(append([]byte{}, data[:33]...), chunk...), data[33:]...)
+
+	stripped, err := stripMetadata(withText, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, data) {
+		t.Errorf("expected the text chunk to be removed")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("expected a valid png, got %v", err)
+	}
+}
+
+func TestConvertMediaStoreUnknownFormats(t *testing.T) {
+	child := &memoryMediaStore{files: make(map[string][]byte)}
+	store := convertMediaStore{
+		childMediaStore: child,
+		convertMap:      map[string]string{"*": "image/webp"},
+		maxWidth:        100,
+		logger:          log.New(os.Stdout, "[test] ", log.Flags()),
+	}
+
+	files := map[string]string{
+		"image/svg+xml": `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"image/avif":    "\x00\x00\x00\x1cftypavif",
+		// the data does not match the content type
+		"image/png": "not a png",
+	}
+	for contentType, content := range files {
+		url, srcset, err := store.SaveMediaVariants(context.Background(), MicropubFile{
+			Name:        "file",
+			ContentType: contentType,
+			Size:        int64(len(content)),
+			Reader:      io.NopCloser(bytes.NewReader([]byte(content))),
+		})
+		if err != nil {
+			t.Fatalf("%s: expected the file to be stored unchanged, got %v", contentType, err)
+		}
+		if srcset != nil || string(child.files[url]) != content {
+			t.Errorf("%s: expected the unchanged file, got %q %v", contentType, child.files[url], srcset)
+		}
+	}
+}
@@ -2,30 +2,26 @@ package micropub
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func (m *micropubApiModule) mediaEndpoint(c *gin.Context) {
-	authorization, err := authToken(c)
-	if err != nil {
-		c.AbortWithError(401, err)
-		return
-	}
-	_, err = m.verifyToken(authorization, []string{"create"})
-	if err != nil {
-		c.AbortWithError(401, err)
+	authorization, scopeChecker := m.authorize(c, []string{"create"})
+	if scopeChecker == nil {
 		return
 	}
 
 	f, err := c.FormFile("file")
 	if err != nil {
-		c.AbortWithError(400, err)
+		m.invalidRequest(c, err)
 		return
 	}
 	file, err := f.Open()
 	if err != nil {
-		c.AbortWithError(400, err)
+		m.invalidRequest(c, err)
 		return
 	}
 	mpFile := MicropubFile{
@@ -36,8 +32,71 @@ func (m *micropubApiModule) mediaEndpoint(c *gin.Context) {
 	}
 	url, err := m.mediaStore.SaveMediaFiles(context.Background(), mpFile)
 	if err != nil {
-		c.AbortWithError(500, err)
+		m.serverError(c, err)
 		return
 	}
+	m.recordMedia(authorization, url, mpFile)
 	c.Header("Location", url)
+	c.Status(201)
+}
+
+func (m *micropubApiModule) mediaQueryEndpoint(c *gin.Context) {
+	_, scopeChecker := m.authorize(c, []string{"create"})
+	if scopeChecker == nil {
+		return
+	}
+	if m.mediaIndex == nil {
+		m.invalidRequest(c, fmt.Errorf("no media index configured, uploaded media can not be queried"))
+		return
+	}
+
+	switch c.Query("q") {
+	case "last":
+		media, err := m.mediaIndex.List(1, 0)
+		if err != nil {
+			m.serverError(c, err)
+			return
+		}
+		if len(media) == 0 {
+			c.JSON(200, gin.H{})
+			return
+		}
+		c.JSON(200, media[0])
+	case "source":
+		limit, offset, err := queryLimitOffset(c)
+		if err != nil {
+			m.invalidRequest(c, err)
+			return
+		}
+		media, err := m.mediaIndex.List(limit, offset)
+		if err != nil {
+			m.serverError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"items": media})
+	default:
+		m.invalidRequest(c, fmt.Errorf("unsupported query: %s", c.Query("q")))
+	}
+}
+
+// recordMedia adds the uploaded file to the media index, if one is configured.
+// Failures are only logged, the file has already been saved.
+func (m *micropubApiModule) recordMedia(token, url string, file MicropubFile) {
+	if m.mediaIndex == nil || url == "" {
+		return
+	}
+	clientId, err := m.clientId(token)
+	if err != nil {
+		m.logger.Printf("unable to resolve client id of uploaded media %s: %v", url, err)
+	}
+	err = m.mediaIndex.Add(UploadedMedia{
+		Url:         url,
+		ContentType: file.ContentType,
+		Size:        file.Size,
+		ClientId:    clientId,
+		Published:   time.Now(),
+	})
+	if err != nil {
+		m.logger.Printf("unable to record uploaded media %s: %v", url, err)
+	}
 }
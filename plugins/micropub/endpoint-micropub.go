@@ -1,27 +1,28 @@
 package micropub
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"tiim/go-comment-api/plugins/indieauth"
+	"strings"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func (m *micropubApiModule) micropubEndpoint(c *gin.Context) {
-	authorization, err := authToken(c)
-	if err != nil {
-		c.AbortWithError(401, err)
-		return
-	}
-	scopeChecker, err := m.verifyToken(authorization, []string{"create"})
-	if err != nil {
-		c.AbortWithError(401, err)
+	// the scope is checked per action
+	authorization, scopeChecker := m.authorize(c, nil)
+	if scopeChecker == nil {
 		return
 	}
 
 	ct := c.ContentType()
 	var data MicropubPostRaw
+	var err error
 	if ct == "application/x-www-form-urlencoded" {
 		data, err = extractFormData(c)
 	} else if ct == "multipart/form-data" {
@@ -29,73 +30,176 @@ func (m *micropubApiModule) micropubEndpoint(c *gin.Context) {
 	} else if ct == "application/json" {
 		data, err = extractJSONData(c)
 	} else {
-		c.AbortWithError(400, fmt.Errorf("unsupported Content-Type: %s", ct))
+		m.invalidRequest(c, fmt.Errorf("unsupported Content-Type: %s", ct))
 		return
 	}
 	if err != nil {
-		c.AbortWithError(400, fmt.Errorf("failed to parse request: %w", err))
+		m.invalidRequest(c, fmt.Errorf("failed to parse request: %w", err))
+		return
+	}
+
+	switch data.Action {
+	case "create", "update", "delete", "undelete":
+		if !scopeChecker(data.Action) {
+			m.insufficientScope(c, data.Action)
+			return
+		}
+	default:
+		m.invalidRequest(c, fmt.Errorf("unsupported action: %s", data.Action))
 		return
 	}
 
 	switch data.Action {
 	case "create":
-		m.actionCreate(c, data)
+		err = validateCreate(data)
 	case "update":
-		m.actionUpdate(c, data, scopeChecker)
-	case "delete":
-		m.actionDelete(c, data, scopeChecker)
+		err = validateUpdate(data)
 	default:
-		c.AbortWithError(400, fmt.Errorf("unsupported action: %s", data.Action))
+		err = validateUrlAction(data)
+	}
+	if err != nil {
+		m.invalidRequest(c, err)
 		return
 	}
+
+	switch data.Action {
+	case "create":
+		m.actionCreate(c, data, authorization)
+	case "update":
+		m.actionUpdate(c, data)
+	case "delete":
+		m.actionDelete(c, data)
+	case "undelete":
+		m.actionUnDelete(c, data)
+	}
 }
 
-func (m *micropubApiModule) actionCreate(c *gin.Context, data MicropubPostRaw) {
+func (m *micropubApiModule) actionCreate(c *gin.Context, data MicropubPostRaw, token string) {
+	syndicators, err := m.selectSyndicators(&data)
+	if err != nil {
+		m.invalidRequest(c, err)
+		return
+	}
 	post := ParseMicropubPost(data)
+	if m.citeFetcher != nil {
+		m.citeFetcher.enrichPost(c.Request.Context(), &post)
+	}
 
 	for _, file := range data.Files {
-		url, err := m.mediaStore.SaveMediaFiles(c, file)
+		url, srcset, err := m.saveMedia(c, file)
 		if err != nil {
-			c.AbortWithError(500, err)
+			m.serverError(c, err)
 			return
 		}
-		addUrlToPost(&post, url, file.Name, file.ContentType, m.logger)
+		m.recordMedia(token, url, file)
+		addUrlToPost(&post, url, srcset, file.Name, file.ContentType, m.logger)
 	}
-	location, err := m.store.Create(post)
+
+	if m.isScheduled(post) {
+		m.schedulePost(c, post, syndicators)
+		return
+	}
+
+	location, err := m.publishPost(post, syndicators)
 	if err != nil {
-		c.AbortWithError(500, err)
+		m.serverError(c, err)
 		return
 	}
+	// the post has been written to the store, it is only processed asynchronously if it is scheduled
+	c.Header("Location", location)
+	c.Status(201)
+}
+
+// publishPost writes the post to the store, notifies the post event handlers and syndicates it in the background.
+func (m *micropubApiModule) publishPost(post MicropubPost, syndicators []syndicator) (string, error) {
+	location, err := m.store.Create(post)
+	if err != nil {
+		return "", err
+	}
 
 	log.Printf("Created post at %s", location)
-	c.Header("Location", location)
-	c.Status(202)
+	if post.Entry.IsDraft() {
+		// drafts are not syndicated and no webmentions are sent until they are published
+		if len(syndicators) > 0 {
+			m.logger.Printf("not syndicating draft %s", location)
+		}
+		return location, nil
+	}
+	if len(syndicators) > 0 {
+		// a slow target does not delay the response, failures are logged by syndicate
+		go m.syndicate(context.Background(), location, post, syndicators)
+	}
+	m.emitPostEvent(postevent.Created, location, postTargets(post.Entry))
+	return location, nil
 }
 
-func (m *micropubApiModule) actionUpdate(c *gin.Context, data MicropubPostRaw, scopeChecker indieauth.ScopeCheck) {
-	if !scopeChecker("update") {
-		c.JSON(401, gin.H{"error": "unauthorized"})
-		return
+// schedulePost adds the post to the queue, it is written to the store at its published date.
+// The url of the post is not known yet, so the location is the preview url of the scheduled post,
+// which redirects to the post once it is published.
+func (m *micropubApiModule) schedulePost(c *gin.Context, post MicropubPost, syndicators []syndicator) {
+	scheduled := scheduledPost{
+		Id:          uuid.New().String(),
+		PublishAt:   post.Entry.Published,
+		Post:        post,
+		SyndicateTo: make([]string, len(syndicators)),
+		Created:     time.Now().UTC(),
+	}
+	for i, s := range syndicators {
+		scheduled.SyndicateTo[i] = s.Target().Uid
 	}
-	err := m.store.Modify(data.Url, data.Delete, data.Add, data.Replace)
+	err := m.queue.Add(scheduled)
 	if err != nil {
-		c.AbortWithError(500, err)
+		m.serverError(c, err)
+		return
+	}
+	m.logger.Printf("Scheduled post %s for %s", scheduled.Id, scheduled.PublishAt.Format(time.RFC3339))
+	c.Header("Location", m.scheduledUrl(scheduled.Id))
+	c.JSON(202, gin.H{"scheduled": scheduled.Id, "published": scheduled.PublishAt.Format(time.RFC3339)})
+}
+
+func (m *micropubApiModule) actionUpdate(c *gin.Context, data MicropubPostRaw) {
+	version := ifMatchVersion(c)
+	err := m.store.Modify(data.Url, version, data.Delete, data.Add, data.Replace)
+	if errors.Is(err, errConflict) && version != "" {
+		m.abortWithError(c, 412, errorConflict, err)
+		return
+	} else if err != nil {
+		m.storeError(c, err)
 		return
 	}
 	log.Printf("Updated post %s", data.Url)
+	m.emitStoredPostEvent(postevent.Updated, data.Url)
 	c.Status(200)
 }
 
-func (m *micropubApiModule) actionDelete(c *gin.Context, data MicropubPostRaw, scopeChecker indieauth.ScopeCheck) {
-	if !scopeChecker("delete") {
-		c.JSON(401, gin.H{"error": "unauthorized"})
-		return
+// ifMatchVersion returns the version of the If-Match header, the ETag returned by q=source.
+// An empty string is returned if the header is missing or matches any version.
+func ifMatchVersion(c *gin.Context) string {
+	etag := strings.TrimSpace(c.GetHeader("If-Match"))
+	if etag == "*" {
+		return ""
 	}
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+func (m *micropubApiModule) actionDelete(c *gin.Context, data MicropubPostRaw) {
 	err := m.store.Delete(data.Url)
 	if err != nil {
-		c.AbortWithError(500, err)
+		m.storeError(c, err)
 		return
 	}
 	log.Printf("Deleted post %s", data.Url)
+	m.emitPostEvent(postevent.Deleted, data.Url, nil)
+	c.Status(200)
+}
+
+func (m *micropubApiModule) actionUnDelete(c *gin.Context, data MicropubPostRaw) {
+	err := m.store.UnDelete(data.Url)
+	if err != nil {
+		m.storeError(c, err)
+		return
+	}
+	log.Printf("Undeleted post %s", data.Url)
+	m.emitStoredPostEvent(postevent.UnDeleted, data.Url)
 	c.Status(200)
 }
@@ -2,43 +2,196 @@ package micropub
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"tiim/go-comment-api/lib/mfobjects"
 
 	"github.com/gin-gonic/gin"
+	"willnorris.com/go/microformats"
 )
 
+type postTypeInfo struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+var supportedPostTypes = []postTypeInfo{
+	{Type: "note", Name: "Note"},
+	{Type: "article", Name: "Article"},
+	{Type: "photo", Name: "Photo"},
+	{Type: "reply", Name: "Reply"},
+	{Type: "like", Name: "Like"},
+	{Type: "repost", Name: "Repost"},
+	{Type: "rsvp", Name: "RSVP"},
+	{Type: "bookmark", Name: "Bookmark"},
+	{Type: "checkin", Name: "Checkin"},
+	{Type: "video", Name: "Video"},
+	{Type: "audio", Name: "Audio"},
+}
+
+var supportedQueries = []string{"config", "source", "syndicate-to", "destination", "category", "post-types"}
+
+const defaultSourceListLimit = 10
+
 func (m *micropubApiModule) queryEndpoint(c *gin.Context) {
-	authorization := c.Request.Header.Get("Authorization")
-	authorization = strings.TrimPrefix(authorization, "Bearer ")
-	authQuery := c.Query("access_token")
-	if authQuery != "" && authorization != "" {
-		c.AbortWithError(400, fmt.Errorf("access_token must not be set in both Authorization header and form data"))
-		return
-	} else if authQuery != "" {
-		authorization = authQuery
-	}
-	_, err := m.verifyToken(authorization, []string{"create"})
-	if err != nil {
-		c.AbortWithError(401, err)
+	_, scopeChecker := m.authorize(c, []string{"create"})
+	if scopeChecker == nil {
 		return
 	}
 
 	switch c.Query("q") {
-	case "config", "syndicate-to":
+	case "config":
 		c.JSON(200, gin.H{
 			"media-endpoint": "/micropub/media",
-			"syndicate-to":   []string{},
+			"syndicate-to":   m.syndicationTargets(),
+			"destination":    m.destinations(),
+			"post-types":     supportedPostTypes,
+			"q":              supportedQueries,
+		})
+	case "destination":
+		c.JSON(200, gin.H{
+			"destination": m.destinations(),
+		})
+	case "syndicate-to":
+		c.JSON(200, gin.H{
+			"syndicate-to": m.syndicationTargets(),
+		})
+	case "post-types":
+		c.JSON(200, gin.H{
+			"post-types": supportedPostTypes,
 		})
+	case "category":
+		m.queryCategory(c)
 	case "source":
-		url := c.Query("url")
-		post, err := m.store.Get(url)
-		if err != nil {
-			c.AbortWithError(404, err)
-			return
+		if c.Query("url") == "" {
+			m.querySourceList(c)
+		} else {
+			m.querySource(c)
 		}
-		c.JSON(200, post)
 	default:
-		c.AbortWithError(400, fmt.Errorf("unsupported query: %s", c.Query("q")))
+		m.invalidRequest(c, fmt.Errorf("unsupported query: %s", c.Query("q")))
+		return
+	}
+}
+
+func (m *micropubApiModule) querySource(c *gin.Context) {
+	url := c.Query("url")
+	post, version, err := m.store.Get(url)
+	if err != nil {
+		m.storeError(c, err)
+		return
+	}
+	if version != "" {
+		// clients can send the version in the If-Match header of an update to detect concurrent changes
+		c.Header("ETag", `"`+version+`"`)
+	}
+
+	properties := queryProperties(c)
+	if len(properties) > 0 {
+		c.JSON(200, gin.H{"properties": filterProperties(post, properties), "post-type": postType(post)})
 		return
 	}
+	c.JSON(200, sourceItem(post))
+}
+
+func (m *micropubApiModule) querySourceList(c *gin.Context) {
+	limit, offset, err := queryLimitOffset(c)
+	if err != nil {
+		m.invalidRequest(c, err)
+		return
+	}
+
+	posts, err := m.store.List(limit, offset)
+	if err != nil {
+		m.serverError(c, err)
+		return
+	}
+
+	properties := queryProperties(c)
+	items := make([]interface{}, len(posts))
+	for i, post := range posts {
+		item := sourceItem(post)
+		if len(properties) > 0 {
+			item["properties"] = filterProperties(post, properties)
+		}
+		items[i] = item
+	}
+	c.JSON(200, gin.H{"items": items})
+}
+
+func (m *micropubApiModule) queryCategory(c *gin.Context) {
+	categories, err := m.store.Categories()
+	if err != nil {
+		m.serverError(c, err)
+		return
+	}
+
+	filter := strings.ToLower(c.Query("filter"))
+	if filter != "" {
+		filtered := make([]string, 0)
+		for _, category := range categories {
+			if strings.Contains(strings.ToLower(category), filter) {
+				filtered = append(filtered, category)
+			}
+		}
+		categories = filtered
+	}
+	c.JSON(200, gin.H{"categories": categories})
+}
+
+// destinations returns the destinations of the store, or an empty list if the store has only one destination.
+func (m *micropubApiModule) destinations() []Destination {
+	if store, ok := m.store.(destinationStore); ok {
+		return store.Destinations()
+	}
+	return []Destination{}
+}
+
+// queryLimitOffset returns the values of the limit and offset query parameters,
+// limit defaults to defaultSourceListLimit and offset to 0.
+func queryLimitOffset(c *gin.Context) (int, int, error) {
+	limit := defaultSourceListLimit
+	offset := 0
+	var err error
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", c.Query("limit"))
+		}
+	}
+	if c.Query("offset") != "" {
+		offset, err = strconv.Atoi(c.Query("offset"))
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", c.Query("offset"))
+		}
+	}
+	return limit, offset, nil
+}
+
+// queryProperties returns the values of the properties[] query parameter.
+func queryProperties(c *gin.Context) []string {
+	properties := c.QueryArray("properties[]")
+	properties = append(properties, c.QueryArray("properties")...)
+	return properties
+}
+
+// sourceItem returns the q=source representation of the post, including the discovered post type.
+func sourceItem(mf *microformats.Microformat) gin.H {
+	return gin.H{"type": mf.Type, "properties": mf.Properties, "post-type": postType(mf)}
+}
+
+// postType returns the post type of the h-entry, see mfobjects.MF2HEntry.PostType.
+func postType(mf *microformats.Microformat) string {
+	entry := mfobjects.GetHEntry(&microformats.Data{Items: []*microformats.Microformat{mf}})
+	return entry.PostType()
+}
+
+func filterProperties(mf *microformats.Microformat, properties []string) map[string][]interface{} {
+	filtered := make(map[string][]interface{})
+	for _, property := range properties {
+		if values, ok := mf.Properties[property]; ok {
+			filtered[property] = values
+		}
+	}
+	return filtered
 }
@@ -0,0 +1,95 @@
+package micropub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiim/go-comment-api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type filesystemMediaStore struct {
+	// the directory the files are stored in
+	directory string
+	// the route the directory is served on, an empty string disables serving the files
+	servePath string
+	formatUrl func(name, contentType string) string
+	logger    *log.Logger
+}
+
+func newFilesystemMediaStore(directory, servePath string, formatUrl func(name, contentType string) string, logger *log.Logger) *filesystemMediaStore {
+	return &filesystemMediaStore{
+		directory: directory,
+		servePath: servePath,
+		formatUrl: formatUrl,
+		logger:    logger,
+	}
+}
+
+func (s *filesystemMediaStore) Name() string {
+	return "micropub-media-filesystem"
+}
+
+func (s *filesystemMediaStore) Init(config config.GlobalConfig) error {
+	return nil
+}
+
+func (s *filesystemMediaStore) Start() error {
+	return nil
+}
+
+func (s *filesystemMediaStore) RegisterRoutes(r *gin.Engine) error {
+	if s.servePath != "" {
+		r.Static(s.servePath, s.directory)
+	}
+	return nil
+}
+
+func (s *filesystemMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	defer file.Reader.Close()
+
+	name := mediaFileName(file.ContentType, s.logger)
+	path, err := mediaFilePath(s.directory, name)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(s.directory, ".tmp-"+name+"-*")
+	if err != nil {
+		return "", fmt.Errorf("could not create file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, file.Reader)
+	if err != nil {
+		f.Close()
+		return "", fmt.Errorf("could not write file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("could not write file: %v", err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return "", fmt.Errorf("could not write file: %v", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", fmt.Errorf("could not write file: %v", err)
+	}
+
+	url := s.formatUrl(name, file.ContentType)
+	s.logger.Printf("Saved %s to %s", file.Name, url)
+	return url, nil
+}
+
+// mediaFilePath returns the path of the file in the directory. The name must be a single path element,
+// so a file can never be written outside of the directory, and must not start with a dot like the temporary files.
+func mediaFilePath(directory, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("unsafe media file name %q", name)
+	}
+	return filepath.Join(directory, name), nil
+}
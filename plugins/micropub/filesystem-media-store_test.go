@@ -0,0 +1,64 @@
+package micropub
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilesystemMediaStore(t *testing.T) {
+	dir := t.TempDir()
+	store := newFilesystemMediaStore(dir, "/media", func(name, contentType string) string {
+		return "https://example.com/media/" + name
+	}, log.New(os.Stdout, "[test] ", log.Flags()))
+
+	url, err := store.SaveMediaFiles(context.Background(), MicropubFile{
+		Name:        "../../photo.jpg",
+		ContentType: "image/jpeg",
+		Size:        4,
+		Reader:      io.NopCloser(strings.NewReader("jpeg")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the name of the uploaded file is not used, the file gets a random name with the extension of its type
+	name := strings.TrimPrefix(url, "https://example.com/media/")
+	if !regexp.MustCompile(`^[0-9a-f-]{36}\.jpg$`).MatchString(name) {
+		t.Errorf("unexpected generated file name %q", name)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "content", string(content), "jpeg")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the saved file in the directory, got %d entries", len(entries))
+	}
+	if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expected the file to be readable, got %v %v", info, err)
+	}
+}
+
+func TestMediaFilePath(t *testing.T) {
+	path, err := mediaFilePath("media", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "path", path, filepath.Join("media", "photo.jpg"))
+
+	for _, name := range []string{"", ".", "..", "../photo.jpg", "a/photo.jpg", `a\photo.jpg`, ".tmp-photo.jpg"} {
+		if _, err := mediaFilePath("media", name); err == nil {
+			t.Errorf("expected the unsafe file name %q to be rejected", name)
+		}
+	}
+}
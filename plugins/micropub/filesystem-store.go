@@ -0,0 +1,348 @@
+package micropub
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"willnorris.com/go/microformats"
+)
+
+type micropubFilesystemStore struct {
+	// the root directory all paths are relative to
+	directory string
+	// the folder inside the directory where new posts are created
+	folder string
+	// the folder inside the directory where deleted posts are moved to,
+	// an empty string means posts are deleted permanently
+	trashFolder string
+	// the template for the paths of new posts inside folder
+	pathTemplates postPathTemplates
+	format        postFormat
+	urlConverter  UrlConverter
+	rand          *rand.Rand
+	mu            sync.Mutex
+	logger        *log.Logger
+}
+
+func newMicropubFilesystemStore(directory, folder, trashFolder string, pathTemplates postPathTemplates, format postFormat, urlConverter UrlConverter, logger *log.Logger) *micropubFilesystemStore {
+	return &micropubFilesystemStore{
+		directory:     directory,
+		folder:        folder,
+		trashFolder:   trashFolder,
+		pathTemplates: pathTemplates,
+		format:        format,
+		urlConverter:  urlConverter,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:        logger,
+	}
+}
+
+func (m *micropubFilesystemStore) Create(post MicropubPost) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filePath, err := nextPostPath(m.folder, m.pathTemplates.forPost(post), post, m.rand, m.exists)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := m.format.Render(post)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := m.fullPath(filePath + ".md")
+	if err != nil {
+		return "", err
+	}
+	m.logger.Printf("creating post on filesystem: %s", filePath)
+	err = writeFileAtomic(path, []byte(content))
+	if err != nil {
+		return "", fmt.Errorf("unable to write post %s: %w", filePath, err)
+	}
+	return m.urlConverter.FilePathToUrl(filePath), nil
+}
+
+func (m *micropubFilesystemStore) Modify(u, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filePath := m.urlConverter.UrlToFilePath(u)
+	path, err := m.fullPath(filePath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return errPostNotFound
+	} else if err != nil {
+		return fmt.Errorf("unable to read post %s: %w", filePath, err)
+	}
+	if version != "" && postVersion(content) != version {
+		return errConflict
+	}
+
+	post, err := m.format.Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("unable to parse post %s: %w", filePath, err)
+	}
+	err = ModifyEntry(&post, deleteProps, addProps, replaceProps)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := m.format.Render(post)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(path, []byte(rendered))
+	if err != nil {
+		return fmt.Errorf("unable to write post %s: %w", filePath, err)
+	}
+	return nil
+}
+
+func (m *micropubFilesystemStore) Delete(u string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filePath := m.urlConverter.UrlToFilePath(u)
+	path, err := m.fullPath(filePath)
+	if err != nil {
+		return err
+	}
+	if m.trashFolder == "" {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return errPostNotFound
+		} else if err != nil {
+			return fmt.Errorf("unable to delete post %s: %w", filePath, err)
+		}
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return errPostNotFound
+	}
+	trashPath, err := m.trashPath(filePath)
+	if err != nil {
+		return err
+	}
+	err = moveFile(path, trashPath)
+	if err != nil {
+		return fmt.Errorf("unable to move post %s to trash: %w", filePath, err)
+	}
+	return nil
+}
+
+func (m *micropubFilesystemStore) UnDelete(u string) error {
+	if m.trashFolder == "" {
+		return fmt.Errorf("undelete requires a trash folder to be configured")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filePath := m.urlConverter.UrlToFilePath(u)
+	path, err := m.fullPath(filePath)
+	if err != nil {
+		return err
+	}
+	trashPath, err := m.trashPath(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("post %s already exists", filePath)
+	}
+	if _, err := os.Stat(trashPath); os.IsNotExist(err) {
+		return errPostNotFound
+	}
+	err = moveFile(trashPath, path)
+	if err != nil {
+		return fmt.Errorf("unable to restore post %s from trash: %w", filePath, err)
+	}
+	return nil
+}
+
+func (m *micropubFilesystemStore) Get(u string) (*microformats.Microformat, string, error) {
+	filePath := m.urlConverter.UrlToFilePath(u)
+	path, err := m.fullPath(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if m.trashFolder != "" {
+			if trashPath, err := m.trashPath(filePath); err == nil {
+				if _, err := os.Stat(trashPath); err == nil {
+					return nil, "", errPostDeleted
+				}
+			}
+		}
+		return nil, "", errPostNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	post, err := m.format.Parse(string(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to parse post %s: %w", filePath, err)
+	}
+	return post.Entry.ToMicroformat(), postVersion(content), nil
+}
+
+func (m *micropubFilesystemStore) List(limit, offset int) ([]*microformats.Microformat, error) {
+	posts, err := m.allPosts()
+	if err != nil {
+		return nil, err
+	}
+	return postsToMicroformats(posts, limit, offset), nil
+}
+
+func (m *micropubFilesystemStore) Categories() ([]string, error) {
+	posts, err := m.allPosts()
+	if err != nil {
+		return nil, err
+	}
+	return postCategories(posts), nil
+}
+
+// allPosts reads all posts in the folder of the store, with the url of the entries set.
+func (m *micropubFilesystemStore) allPosts() ([]MicropubPost, error) {
+	root := filepath.Join(m.directory, filepath.FromSlash(m.folder))
+	trash := ""
+	if m.trashFolder != "" {
+		trash = filepath.Join(m.directory, filepath.FromSlash(m.trashFolder))
+	}
+	posts := make([]MicropubPost, 0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == trash {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".md") || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(m.directory, path)
+		if err != nil {
+			return err
+		}
+		post, err := m.format.Parse(string(content))
+		if err != nil {
+			// a broken file should not hide all other posts
+			m.logger.Printf("skipping post %s: unable to parse: %v", rel, err)
+			return nil
+		}
+		post.Entry.Url = m.urlConverter.FilePathToUrl(filepath.ToSlash(rel))
+		posts = append(posts, post)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read posts: %w", err)
+	}
+	return posts, nil
+}
+
+// exists reports whether a post with the path (without extension) exists, including posts in the trash.
+func (m *micropubFilesystemStore) exists(filePath string) (bool, error) {
+	path, err := m.fullPath(filePath + ".md")
+	if err != nil {
+		return false, err
+	}
+	paths := []string{path}
+	if m.trashFolder != "" {
+		trashPath, err := m.trashPath(filePath + ".md")
+		if err != nil {
+			return false, err
+		}
+		paths = append(paths, trashPath)
+	}
+	for _, path := range paths {
+		_, err := os.Stat(path)
+		if err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// fullPath returns the path of the post file on disk. The file path is derived from the request url,
+// a path that is not inside the folder of the store is rejected with errPostNotFound.
+func (m *micropubFilesystemStore) fullPath(filePath string) (string, error) {
+	return pathInside(filepath.Join(m.directory, filepath.FromSlash(m.folder)), filepath.Join(m.directory, filepath.FromSlash(filePath)))
+}
+
+// trashPath returns the path of the deleted post file in the trash folder.
+func (m *micropubFilesystemStore) trashPath(filePath string) (string, error) {
+	trash := filepath.Join(m.directory, filepath.FromSlash(m.trashFolder))
+	return pathInside(filepath.Join(trash, filepath.FromSlash(m.folder)), filepath.Join(trash, filepath.FromSlash(filePath)))
+}
+
+// pathInside cleans the path and returns it if it is inside the root directory.
+func pathInside(root, path string) (string, error) {
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is outside of the posts folder", errPostNotFound, path)
+	}
+	return path, nil
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory
+// and renames it to path afterwards, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func moveFile(from, to string) error {
+	err := os.MkdirAll(filepath.Dir(to), 0755)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
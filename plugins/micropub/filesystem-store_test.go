@@ -0,0 +1,118 @@
+package micropub
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFilesystemStore(t *testing.T, trashFolder string) (*micropubFilesystemStore, string) {
+	dir := t.TempDir()
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	return newMicropubFilesystemStore(dir, "posts/", trashFolder, postPathTemplates{}, defaultPostFormat, mapper, logger), dir
+}
+
+func TestFilesystemStore(t *testing.T) {
+	store, dir := newTestFilesystemStore(t, "trash")
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello World"
+	post.Entry.Published = time.Now().UTC()
+	url, err := store.Create(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := store.urlConverter.UrlToFilePath(url)
+	if _, err := os.Stat(filepath.Join(dir, filePath)); err != nil {
+		t.Fatalf("expected the post to be written to %s: %v", filePath, err)
+	}
+
+	mf, version, err := store.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "content", mf.Properties["content"], []interface{}{"Hello World"})
+
+	if err := store.Modify(url, version, nil, map[string][]interface{}{"category": {"test"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Modify(url, version, nil, map[string][]interface{}{"category": {"stale"}}, nil); !errors.Is(err, errConflict) {
+		t.Errorf("expected a conflict for a stale version, got %v", err)
+	}
+	mf, _, err = store.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "category", mf.Properties["category"], []interface{}{"test"})
+
+	if err := store.Delete(url); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(url); !errors.Is(err, errPostDeleted) {
+		t.Errorf("expected errPostDeleted after delete, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "trash", filePath)); err != nil {
+		t.Errorf("expected the post to be moved to the trash: %v", err)
+	}
+
+	if err := store.UnDelete(url); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(url); err != nil {
+		t.Errorf("expected the post to be restored, got %v", err)
+	}
+	if err := store.UnDelete("https://example.com/missing"); !errors.Is(err, errPostNotFound) {
+		t.Errorf("expected errPostNotFound for a post that is not in the trash, got %v", err)
+	}
+}
+
+func TestFilesystemStoreRejectsPathTraversal(t *testing.T) {
+	store, dir := newTestFilesystemStore(t, "trash")
+	secret := filepath.Join(dir, "secret.md")
+	if err := os.WriteFile(secret, []byte("---\ncontent: secret\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{"https://example.com/../secret", "https://example.com/a/../../secret", "https://example.com/../../../etc/passwd"} {
+		if _, _, err := store.Get(url); !errors.Is(err, errPostNotFound) {
+			t.Errorf("Get(%s): expected errPostNotFound, got %v", url, err)
+		}
+		if err := store.Modify(url, "", nil, nil, map[string][]interface{}{"content": {"changed"}}); !errors.Is(err, errPostNotFound) {
+			t.Errorf("Modify(%s): expected errPostNotFound, got %v", url, err)
+		}
+		if err := store.Delete(url); !errors.Is(err, errPostNotFound) {
+			t.Errorf("Delete(%s): expected errPostNotFound, got %v", url, err)
+		}
+		if err := store.UnDelete(url); !errors.Is(err, errPostNotFound) {
+			t.Errorf("UnDelete(%s): expected errPostNotFound, got %v", url, err)
+		}
+	}
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("expected the file outside of the folder to be untouched: %v", err)
+	}
+}
+
+func TestFilesystemStoreSkipsBrokenPosts(t *testing.T) {
+	store, dir := newTestFilesystemStore(t, "")
+	post := MicropubPost{}
+	post.Entry.Content = "Hello"
+	post.Entry.Published = time.Now().UTC()
+	if _, err := store.Create(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "posts", "broken.md"), []byte("---\ncontent: [unclosed\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err := store.List(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("expected the broken post to be skipped, got %d posts", len(posts))
+	}
+}
@@ -0,0 +1,161 @@
+package micropub
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"willnorris.com/go/microformats"
+)
+
+// micropubGitStore writes posts to a local git working copy using the filesystem store
+// and creates a commit for every change.
+type micropubGitStore struct {
+	fs *micropubFilesystemStore
+	// the remote to push to after every commit, an empty string disables pushing
+	remote string
+	// the branch on the remote to push to, an empty string pushes to the upstream of the current branch
+	branch      string
+	authorName  string
+	authorEmail string
+	mu          sync.Mutex
+	// gitMu guards the index of the working copy while a change is added, committed and pushed
+	gitMu  sync.Mutex
+	logger *log.Logger
+}
+
+func newMicropubGitStore(fs *micropubFilesystemStore, remote, branch, authorName, authorEmail string, logger *log.Logger) *micropubGitStore {
+	return &micropubGitStore{
+		fs:          fs,
+		remote:      remote,
+		branch:      branch,
+		authorName:  authorName,
+		authorEmail: authorEmail,
+		logger:      logger,
+	}
+}
+
+func (m *micropubGitStore) Create(post MicropubPost) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	url, err := m.fs.Create(post)
+	if err != nil {
+		return "", err
+	}
+	filePath := m.fs.urlConverter.UrlToFilePath(url)
+	err = m.commit("create post "+filePath, filePath)
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+func (m *micropubGitStore) Modify(u, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.fs.Modify(u, version, deleteProps, addProps, replaceProps)
+	if err != nil {
+		return err
+	}
+	filePath := m.fs.urlConverter.UrlToFilePath(u)
+	return m.commit("update post "+filePath, filePath)
+}
+
+func (m *micropubGitStore) Delete(u string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.fs.Delete(u)
+	if err != nil {
+		return err
+	}
+	filePath := m.fs.urlConverter.UrlToFilePath(u)
+	return m.commit("delete post "+filePath, m.changedPaths(filePath)...)
+}
+
+func (m *micropubGitStore) UnDelete(u string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.fs.UnDelete(u)
+	if err != nil {
+		return err
+	}
+	filePath := m.fs.urlConverter.UrlToFilePath(u)
+	return m.commit("undelete post "+filePath, m.changedPaths(filePath)...)
+}
+
+func (m *micropubGitStore) Get(u string) (*microformats.Microformat, string, error) {
+	return m.fs.Get(u)
+}
+
+func (m *micropubGitStore) List(limit, offset int) ([]*microformats.Microformat, error) {
+	return m.fs.List(limit, offset)
+}
+
+func (m *micropubGitStore) Categories() ([]string, error) {
+	return m.fs.Categories()
+}
+
+// changedPaths returns the paths touched when a post is moved to or from the trash.
+func (m *micropubGitStore) changedPaths(filePath string) []string {
+	if m.fs.trashFolder == "" {
+		return []string{filePath}
+	}
+	return []string{filePath, m.fs.trashFolder + "/" + filePath}
+}
+
+// commit commits the changes of the paths. Other changes in the index or the working copy,
+// for example manual edits, are not included in the commit.
+func (m *micropubGitStore) commit(message string, paths ...string) error {
+	m.gitMu.Lock()
+	defer m.gitMu.Unlock()
+
+	args := append([]string{"add", "--all", "--"}, paths...)
+	if _, err := m.git(args...); err != nil {
+		return err
+	}
+	author := fmt.Sprintf("%s <%s>", m.authorName, m.authorEmail)
+	args = append([]string{"commit", "--quiet", "--author", author, "--message", message, "--only", "--"}, paths...)
+	if _, err := m.git(args...); err != nil {
+		return err
+	}
+	m.logger.Printf("committed: %s", message)
+
+	if m.remote == "" {
+		return nil
+	}
+	ref := "HEAD"
+	if m.branch != "" {
+		ref = "HEAD:refs/heads/" + m.branch
+	}
+	if _, err := m.git("push", "--quiet", m.remote, ref); err != nil {
+		// the change is committed locally and will be pushed with the next commit
+		m.logger.Printf("unable to push to %s: %v", m.remote, err)
+	}
+	return nil
+}
+
+func (m *micropubGitStore) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = m.fs.directory
+	cmd.Env = append(os.Environ(),
+		"GIT_COMMITTER_NAME="+m.authorName,
+		"GIT_COMMITTER_EMAIL="+m.authorEmail,
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
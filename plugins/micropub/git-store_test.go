@@ -0,0 +1,146 @@
+package micropub
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newTestGitStore(t *testing.T, trashFolder string) (*micropubGitStore, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+
+	tmp := t.TempDir()
+	remote := filepath.Join(tmp, "remote.git")
+	work := filepath.Join(tmp, "work")
+	runGit(t, tmp, "init", "--quiet", "--bare", "--initial-branch=main", remote)
+	runGit(t, tmp, "clone", "--quiet", remote, work)
+	runGit(t, work, "checkout", "--quiet", "-b", "main")
+	runGit(t, work, "commit", "--quiet", "--allow-empty", "-m", "initial commit")
+	runGit(t, work, "push", "--quiet", "origin", "main")
+
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	fs := newMicropubFilesystemStore(work, "posts/", trashFolder, postPathTemplates{}, defaultPostFormat, mapper, logger)
+	return newMicropubGitStore(fs, "origin", "main", "https://example.com/", "micropub@example.com", logger), remote
+}
+
+func TestGitStoreCommitsAndPushes(t *testing.T) {
+	store, remote := newTestGitStore(t, "")
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello World"
+	post.Entry.Published = time.Now().UTC()
+
+	url, err := store.Create(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url, "https://example.com/") {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	err = store.Modify(url, "", nil, map[string][]interface{}{"category": {"test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mf, _, err := store.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mf.Properties["category"]) != 1 {
+		t.Errorf("expected category to be set, got %v", mf.Properties)
+	}
+
+	err = store.Delete(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logOutput := runGit(t, remote, "log", "--format=%an <%ae>|%s", "main")
+	lines := strings.Split(logOutput, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 commits on the remote, got %d:\n%s", len(lines), logOutput)
+	}
+	filePath := store.fs.urlConverter.UrlToFilePath(url)
+	expected := []string{"delete post " + filePath, "update post " + filePath, "create post " + filePath}
+	for i, msg := range expected {
+		if lines[i] != "https://example.com/ <micropub@example.com>|"+msg {
+			t.Errorf("commit %d: expected %q, got %q", i, msg, lines[i])
+		}
+	}
+}
+
+func TestGitStoreUnDelete(t *testing.T) {
+	store, remote := newTestGitStore(t, ".trash")
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello World"
+	post.Entry.Published = time.Now().UTC()
+
+	url, err := store.Create(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(url); err == nil {
+		t.Errorf("expected deleted post to not be found")
+	}
+	err = store.UnDelete(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(url); err != nil {
+		t.Errorf("expected restored post to be found: %v", err)
+	}
+
+	files := runGit(t, remote, "ls-tree", "-r", "--name-only", "main")
+	if files != store.fs.urlConverter.UrlToFilePath(url) {
+		t.Errorf("expected only the restored post in the remote tree, got:\n%s", files)
+	}
+}
+
+func TestGitStoreCommitsOnlyThePost(t *testing.T) {
+	store, _ := newTestGitStore(t, "")
+	work := store.fs.directory
+	if err := os.WriteFile(filepath.Join(work, "draft.txt"), []byte("work in progress"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "draft.txt")
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello World"
+	post.Entry.Published = time.Now().UTC()
+	url, err := store.Create(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := runGit(t, work, "show", "--name-only", "--format=", "HEAD")
+	if files != store.fs.urlConverter.UrlToFilePath(url) {
+		t.Errorf("expected only the post in the commit, got:\n%s", files)
+	}
+	if staged := runGit(t, work, "diff", "--cached", "--name-only"); staged != "draft.txt" {
+		t.Errorf("expected the unrelated change to stay staged, got:\n%s", staged)
+	}
+}
@@ -0,0 +1,164 @@
+package micropub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// giteaContents implements repoContents with the contents api of Gitea and Forgejo.
+type giteaContents struct {
+	// the url of the instance, without the /api/v1 suffix
+	baseUrl string
+	token   string
+	owner   string
+	repo    string
+	client  *http.Client
+	logger  *log.Logger
+
+	// mu guards defaultBranch, which is read on first use
+	mu            sync.Mutex
+	defaultBranch string
+}
+
+func newGiteaContents(baseUrl, token, owner, repo string, client *http.Client, logger *log.Logger) *giteaContents {
+	return &giteaContents{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+		owner:   owner,
+		repo:    repo,
+		client:  client,
+		logger:  logger,
+	}
+}
+
+// getFile returns the decoded content of the file and its blob sha as version.
+func (m *giteaContents) getFile(filePath, ref string) (string, string, error) {
+	path := "/contents/" + escapePath(filePath)
+	if ref != "" {
+		path += "?" + url.Values{"ref": {ref}}.Encode()
+	}
+	var file struct {
+		Content string `json:"content"`
+		Sha     string `json:"sha"`
+	}
+	_, err := m.api(http.MethodGet, path, nil, &file)
+	if errors.Is(err, errRepoNotFound) {
+		return "", "", errPostNotFound
+	} else if err != nil {
+		return "", "", err
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return "", "", err
+	}
+	return string(content), file.Sha, nil
+}
+
+func (m *giteaContents) putFile(filePath string, content []byte, sha, branch, message string) error {
+	data := map[string]interface{}{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(content),
+	}
+	if branch != "" {
+		data["branch"] = branch
+	}
+	// gitea creates files with POST and updates them with PUT
+	method := http.MethodPost
+	if sha != "" {
+		method = http.MethodPut
+		data["sha"] = sha
+	}
+	_, err := m.api(method, "/contents/"+escapePath(filePath), data, nil)
+	return err
+}
+
+func (m *giteaContents) deleteFile(filePath, sha, branch, message string) error {
+	data := map[string]interface{}{
+		"message": message,
+		"sha":     sha,
+	}
+	if branch != "" {
+		data["branch"] = branch
+	}
+	_, err := m.api(http.MethodDelete, "/contents/"+escapePath(filePath), data, nil)
+	return err
+}
+
+func (m *giteaContents) listFiles(folder, ref string) ([]repoEntry, error) {
+	if ref == "" {
+		var err error
+		if ref, err = m.repoDefaultBranch(); err != nil {
+			return nil, err
+		}
+	}
+	folder = folderPrefix(folder)
+	files := make([]repoEntry, 0)
+	// the tree is paginated, truncated is set as long as there are more pages
+	for page := 1; ; page++ {
+		var tree struct {
+			Tree []struct {
+				Path string `json:"path"`
+				Type string `json:"type"`
+				Sha  string `json:"sha"`
+			} `json:"tree"`
+			Truncated bool `json:"truncated"`
+		}
+		query := url.Values{"recursive": {"true"}, "page": {strconv.Itoa(page)}}
+		_, err := m.api(http.MethodGet, "/git/trees/"+url.PathEscape(ref)+"?"+query.Encode(), nil, &tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range tree.Tree {
+			if entry.Type == "blob" && strings.HasPrefix(entry.Path, folder) {
+				files = append(files, repoEntry{path: entry.Path, blob: entry.Sha})
+			}
+		}
+		if !tree.Truncated || len(tree.Tree) == 0 {
+			return files, nil
+		}
+	}
+}
+
+// repoDefaultBranch returns the default branch of the repository, it is read once.
+func (m *giteaContents) repoDefaultBranch() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.defaultBranch != "" {
+		return m.defaultBranch, nil
+	}
+
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := m.api(http.MethodGet, "", nil, &repo); err != nil {
+		return "", fmt.Errorf("unable to read the default branch of %s/%s: %w", m.owner, m.repo, err)
+	}
+	m.defaultBranch = repo.DefaultBranch
+	return repo.DefaultBranch, nil
+}
+
+// api sends a request to the repository endpoint of the Gitea API and decodes the JSON response into result.
+func (m *giteaContents) api(method, path string, body, result interface{}) (http.Header, error) {
+	header := http.Header{
+		"Authorization": {"token " + m.token},
+		"Accept":        {"application/json"},
+	}
+	u := m.baseUrl + "/api/v1/repos/" + url.PathEscape(m.owner) + "/" + url.PathEscape(m.repo) + path
+	return forgeRequest(m.client, method, u, header, body, result, giteaStatusError)
+}
+
+// giteaStatusError returns errConflict if a file is created that already exists,
+// Gitea responds to it with 422 Unprocessable Entity.
+func giteaStatusError(status int, body []byte) error {
+	if status == http.StatusUnprocessableEntity && strings.Contains(string(body), "already exists") {
+		return fmt.Errorf("%w: status code %d: %s", errConflict, status, string(body))
+	}
+	return statusError(status, body)
+}
@@ -0,0 +1,145 @@
+package micropub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// githubContents implements repoContents with the contents api of GitHub.
+type githubContents struct {
+	apiUrl string
+	token  string
+	user   string
+	repo   string
+	client *http.Client
+	logger *log.Logger
+
+	// mu guards defaultBranch, which is read on first use
+	mu            sync.Mutex
+	defaultBranch string
+}
+
+func newGithubContents(apiUrl, token, user, repo string, client *http.Client, logger *log.Logger) *githubContents {
+	return &githubContents{
+		apiUrl: strings.TrimSuffix(apiUrl, "/"),
+		token:  token,
+		user:   user,
+		repo:   repo,
+		client: client,
+		logger: logger,
+	}
+}
+
+// repoDefaultBranch returns the default branch of the repository, it is read once.
+func (m *githubContents) repoDefaultBranch() (string, error) {
+	m.mu.Lock()
+	defaultBranch := m.defaultBranch
+	m.mu.Unlock()
+	if defaultBranch != "" {
+		return defaultBranch, nil
+	}
+
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := m.api(http.MethodGet, "", nil, &repo); err != nil {
+		return "", fmt.Errorf("unable to read the default branch of %s/%s: %w", m.user, m.repo, err)
+	}
+	m.mu.Lock()
+	m.defaultBranch = repo.DefaultBranch
+	m.mu.Unlock()
+	return repo.DefaultBranch, nil
+}
+
+// api sends a request to the repository endpoint of the GitHub API and decodes the JSON response into result.
+func (m *githubContents) api(method, path string, body, result interface{}) error {
+	header := http.Header{
+		"Authorization": {"Bearer " + m.token},
+		"Accept":        {"application/vnd.github+json"},
+	}
+	_, err := forgeRequest(m.client, method, m.apiUrl+"/repos/"+m.user+"/"+m.repo+path, header, body, result, statusError)
+	return err
+}
+
+// getFile returns the decoded content of the file and its blob sha as version.
+func (m *githubContents) getFile(filePath, ref string) (string, string, error) {
+	path := "/contents/" + escapePath(filePath)
+	if ref != "" {
+		path += "?" + url.Values{"ref": {ref}}.Encode()
+	}
+	var file struct {
+		Content string `json:"content"`
+		Sha     string `json:"sha"`
+	}
+	err := m.api(http.MethodGet, path, nil, &file)
+	if errors.Is(err, errRepoNotFound) {
+		return "", "", errPostNotFound
+	} else if err != nil {
+		return "", "", err
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return "", "", err
+	}
+	return string(content), file.Sha, nil
+}
+
+func (m *githubContents) putFile(filePath string, content []byte, sha, branch, message string) error {
+	data := map[string]interface{}{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(content),
+	}
+	if sha != "" {
+		data["sha"] = sha
+	}
+	if branch != "" {
+		data["branch"] = branch
+	}
+	return m.api(http.MethodPut, "/contents/"+escapePath(filePath), data, nil)
+}
+
+func (m *githubContents) deleteFile(filePath, sha, branch, message string) error {
+	data := map[string]interface{}{
+		"message": message,
+		"sha":     sha,
+	}
+	if branch != "" {
+		data["branch"] = branch
+	}
+	return m.api(http.MethodDelete, "/contents/"+escapePath(filePath), data, nil)
+}
+
+func (m *githubContents) listFiles(folder, ref string) ([]repoEntry, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+			Sha  string `json:"sha"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := m.api(http.MethodGet, "/git/trees/"+url.PathEscape(ref)+"?recursive=1", nil, &tree); err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		m.logger.Printf("github tree listing of %s/%s is truncated, not all posts are listed", m.user, m.repo)
+	}
+
+	folder = folderPrefix(folder)
+	files := make([]repoEntry, 0)
+	for _, entry := range tree.Tree {
+		if entry.Type == "blob" && strings.HasPrefix(entry.Path, folder) {
+			files = append(files, repoEntry{path: entry.Path, blob: entry.Sha})
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,329 @@
+package micropub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeGithubContents serves a single file of the contents API. The first conflicts PUT requests
+// are rejected with 409 as if the file had been changed concurrently. If invalid is set,
+// PUT requests are rejected with 422 as if the request failed validation.
+type fakeGithubContents struct {
+	content   string
+	sha       string
+	conflicts int
+	invalid   bool
+	puts      int
+}
+
+func (f *fakeGithubContents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]string{
+			"content": base64.StdEncoding.EncodeToString([]byte(f.content)),
+			"sha":     f.sha,
+		})
+	case http.MethodPut:
+		f.puts++
+		var data struct {
+			Content string `json:"content"`
+			Sha     string `json:"sha"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		if f.invalid {
+			w.WriteHeader(422)
+			w.Write([]byte(`{"message":"path contains a malformed path component"}`))
+			return
+		}
+		if f.conflicts > 0 || data.Sha != f.sha {
+			f.conflicts--
+			// another client updated the file in the meantime
+			f.sha += "1"
+			w.WriteHeader(409)
+			return
+		}
+		content, _ := base64.StdEncoding.DecodeString(data.Content)
+		f.content = string(content)
+		f.sha = postVersion(content)
+		w.WriteHeader(200)
+	}
+}
+
+func TestGithubStoreModifyConflicts(t *testing.T) {
+	retryDelay := repoRetryDelay
+	repoRetryDelay = time.Millisecond
+	t.Cleanup(func() { repoRetryDelay = retryDelay })
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello"
+	post.Entry.Published = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake := &fakeGithubContents{content: post.ToMarkdown(), sha: "a"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	store := newMicropubRepoStore(newGithubContents(server.URL, "token", "user", "repo", server.Client(), log.New(os.Stdout, "[test] ", log.Flags())), "posts/", postPathTemplates{}, defaultPostFormat, false, repoCommitOptions{}, mapper, log.New(os.Stdout, "[test] ", log.Flags()))
+	u := "https://example.com/hello"
+
+	_, version, err := store.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "a" {
+		t.Errorf("expected the sha as version, got %s", version)
+	}
+
+	fake.conflicts = 1
+	err = store.Modify(u, version, nil, nil, map[string][]interface{}{"content": {"Changed"}})
+	if !errors.Is(err, errConflict) || fake.puts != 1 {
+		t.Fatalf("expected a conflict without retry for a conditional update, got %v after %d requests", err, fake.puts)
+	}
+
+	fake.conflicts, fake.puts = 2, 0
+	err = store.Modify(u, "", nil, nil, map[string][]interface{}{"content": {"Changed"}})
+	if err != nil {
+		t.Fatalf("expected the update to succeed after retrying, got %v", err)
+	}
+	if fake.puts != 3 {
+		t.Errorf("expected 3 attempts, got %d", fake.puts)
+	}
+	mf, _, err := store.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "content", mf.Properties["content"], []interface{}{"Changed"})
+
+	fake.conflicts, fake.puts = repoMaxAttempts, 0
+	err = store.Modify(u, "", nil, nil, map[string][]interface{}{"content": {"Again"}})
+	if !errors.Is(err, errConflict) || fake.puts != repoMaxAttempts {
+		t.Errorf("expected a conflict after %d attempts, got %v after %d", repoMaxAttempts, err, fake.puts)
+	}
+
+	// a request that fails validation is not a conflict and is not retried
+	fake.conflicts, fake.puts, fake.invalid = 0, 0, true
+	err = store.Modify(u, "", nil, nil, map[string][]interface{}{"content": {"Invalid"}})
+	if err == nil || errors.Is(err, errConflict) || fake.puts != 1 {
+		t.Errorf("expected a plain error without retry, got %v after %d requests", err, fake.puts)
+	}
+}
+
+// fakeGithubGitData serves the git data api of a repository with a single branch "main".
+type fakeGithubGitData struct {
+	head    string
+	files   map[string]string
+	blobs   map[string]string
+	trees   map[string][]string
+	commits int
+}
+
+func (f *fakeGithubGitData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/repos/user/repo")
+	var data struct {
+		Content string `json:"content"`
+		Tree    []struct {
+			Path string  `json:"path"`
+			Sha  *string `json:"sha"`
+		} `json:"tree"`
+		Parents []string `json:"parents"`
+		Sha     string   `json:"sha"`
+	}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&data)
+	}
+	switch {
+	case r.Method == http.MethodGet && path == "/git/ref/heads/main":
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": map[string]string{"sha": f.head}})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/contents/"):
+		content, ok := f.files[strings.TrimPrefix(path, "/contents/")]
+		if !ok || r.URL.Query().Get("ref") != f.head {
+			w.WriteHeader(404)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(content)), "sha": postVersion([]byte(content))})
+	case r.Method == http.MethodGet && path == "/git/commits/"+f.head:
+		json.NewEncoder(w).Encode(map[string]interface{}{"tree": map[string]string{"sha": "tree-" + f.head}})
+	case r.Method == http.MethodPost && path == "/git/blobs":
+		content, _ := base64.StdEncoding.DecodeString(data.Content)
+		sha := postVersion(content)
+		f.blobs[sha] = string(content)
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"sha": sha})
+	case r.Method == http.MethodPost && path == "/git/trees":
+		sha := fmt.Sprintf("tree%d", len(f.trees))
+		for _, entry := range data.Tree {
+			f.trees[sha] = append(f.trees[sha], entry.Path)
+			if entry.Sha == nil {
+				delete(f.files, entry.Path)
+			} else {
+				f.files[entry.Path] = f.blobs[*entry.Sha]
+			}
+		}
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"sha": sha})
+	case r.Method == http.MethodPost && path == "/git/commits":
+		if len(data.Parents) != 1 || data.Parents[0] != f.head {
+			w.WriteHeader(400)
+			return
+		}
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"sha": fmt.Sprintf("commit%d", f.commits+1)})
+	case r.Method == http.MethodPatch && path == "/git/refs/heads/main":
+		f.commits++
+		f.head = data.Sha
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": map[string]string{"sha": f.head}})
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestGithubStoreGitDataCommitsMediaWithPost(t *testing.T) {
+	fake := &fakeGithubGitData{head: "commit0", files: map[string]string{}, blobs: map[string]string{}, trees: map[string][]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	commit := repoCommitOptions{branch: "main", gitData: true, stagingDir: t.TempDir()}
+	newStore := func() *micropubRepoStore {
+		return newMicropubRepoStore(newGithubContents(server.URL, "token", "user", "repo", server.Client(), logger), "posts/", postPathTemplates{}, defaultPostFormat, false, commit, mapper, logger)
+	}
+	store := newStore()
+	media := newRepoMediaStore(store, "media/", "https://example.com/media/", logger)
+	upload := func(content string) string {
+		u, err := media.SaveMediaFiles(context.Background(), MicropubFile{Name: "photo.jpg", ContentType: "image/jpeg", Reader: io.NopCloser(strings.NewReader(content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	photo, other := upload("jpeg"), upload("other")
+	if fake.commits != 0 {
+		t.Fatalf("expected the media to be staged, got %d commits", fake.commits)
+	}
+
+	post := ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+		"content": {"Hello"},
+		"photo":   {photo},
+	}})
+	u, err := store.Create(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.commits != 1 || len(fake.trees["tree0"]) != 2 {
+		t.Fatalf("expected the post and its media in a single commit, got %d commits with trees %v", fake.commits, fake.trees)
+	}
+	if fake.files["media/"+strings.TrimPrefix(photo, "https://example.com/media/")] != "jpeg" {
+		t.Errorf("expected the media file to be committed, got files %v", fake.files)
+	}
+	if len(store.staged) != 1 || store.staged[0].path != "media/"+strings.TrimPrefix(other, "https://example.com/media/") {
+		t.Errorf("expected only the unused media to stay staged, got %v", store.staged)
+	}
+
+	// the unused media is kept in the staging directory and committed on its own after a restart
+	restarted := newStore()
+	if err := restarted.loadStaged(); err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.staged) != 1 || string(restarted.staged[0].content) != "other" {
+		t.Fatalf("expected the unused media to be restored, got %v", restarted.staged)
+	}
+	restarted.commitStaged()
+	if fake.commits != 2 || fake.files["media/"+strings.TrimPrefix(other, "https://example.com/media/")] != "other" {
+		t.Fatalf("expected the unused media to be committed, got %d commits and files %v", fake.commits, fake.files)
+	}
+	if entries, _ := os.ReadDir(commit.stagingDir + "/media"); len(entries) != 0 {
+		t.Errorf("expected the staging directory to be empty, got %d files", len(entries))
+	}
+	// the first store stands for the process before the restart, its queue is dropped
+	store.takeStaged(nil)
+
+	if err := store.Delete(u); err != nil {
+		t.Fatal(err)
+	}
+	if fake.commits != 3 || len(fake.files) != 2 {
+		t.Errorf("expected the post to be deleted in a third commit, got %d commits and files %v", fake.commits, fake.files)
+	}
+}
+
+func TestGithubStoreListQueries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fake := &fakeGithub{fakeForge{files: map[string]string{}, commits: map[string]int{}}}
+	for i, published := range []string{"2023-03-01", "2023-01-01", "2023-02-01", "2023-04-01"} {
+		post := MicropubPost{}
+		post.Entry.Content = fmt.Sprintf("Post %d", i)
+		post.Entry.Published, _ = time.Parse("2006-01-02", published)
+		post.Entry.Category = []string{fmt.Sprintf("tag%d", i%2)}
+		if i == 3 {
+			post.Entry.PostStatus = mfobjects.PostStatusDraft
+			post.Entry.Category = []string{"draft"}
+		}
+		fake.write(fmt.Sprintf("posts/post%d.md", i), post.ToMarkdown())
+	}
+	fake.write("posts/image.jpg", "jpeg")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	store := newMicropubRepoStore(newGithubContents(server.URL, "token", "user", "repo", server.Client(), logger), "posts/", postPathTemplates{}, defaultPostFormat, false, repoCommitOptions{}, mapper, logger)
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, nil, nil, nil, stubVerifyToken, nil, logger)
+	r := gin.New()
+	if err := api.RegisterRoutes(r); err != nil {
+		t.Fatal(err)
+	}
+	query := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/micropub?"+query, nil)
+		req.Header.Set("Authorization", "Bearer full-token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	source := func(q string) []string {
+		w := query(q)
+		if w.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d: %s", q, w.Code, w.Body.String())
+		}
+		var list struct {
+			Items []struct {
+				Properties map[string][]interface{} `json:"properties"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		urls := make([]string, len(list.Items))
+		for i, item := range list.Items {
+			urls[i], _ = item.Properties["url"][0].(string)
+		}
+		return urls
+	}
+
+	// newest first, without the draft
+	assertEqual(t, "all posts", source("q=source"), []string{"https://example.com/post0", "https://example.com/post2", "https://example.com/post1"})
+	assertEqual(t, "limit", source("q=source&limit=2"), []string{"https://example.com/post0", "https://example.com/post2"})
+	assertEqual(t, "limit and offset", source("q=source&limit=2&offset=2"), []string{"https://example.com/post1"})
+	assertEqual(t, "offset after the last post", source("q=source&offset=5"), []string{})
+
+	w := query("q=category")
+	assertEqual(t, "categories", strings.TrimSpace(w.Body.String()), `{"categories":["tag0","tag1"]}`)
+	w = query("q=source&limit=invalid")
+	assertEqual(t, "status of an invalid limit", w.Code, 400)
+}
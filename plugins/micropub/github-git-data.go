@@ -0,0 +1,291 @@
+package micropub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// media that is not used by a post within this duration is committed on its own
+var stagedMediaDelay = 2 * time.Minute
+
+// StageFile adds a media file to the commit of the post that uses it, so a post and its media are committed
+// together. The file is kept in the staging directory until it is committed, so it survives a restart.
+// If no post uses the file within stagedMediaDelay, it is committed on its own.
+func (m *micropubRepoStore) StageFile(path string, content []byte) error {
+	if !m.commit.gitData {
+		return fmt.Errorf("staging files requires the git data api to be enabled")
+	}
+	stagedPath, err := m.stagedPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(stagedPath), 0755); err != nil {
+		return fmt.Errorf("unable to create staging directory: %w", err)
+	}
+	if err := os.WriteFile(stagedPath, content, 0644); err != nil {
+		return fmt.Errorf("unable to stage %s: %w", path, err)
+	}
+	m.restage([]repoFile{{path: path, content: content}})
+	return nil
+}
+
+// loadStaged stages the files that were left in the staging directory by a previous run.
+func (m *micropubRepoStore) loadStaged() error {
+	if m.commit.stagingDir == "" {
+		return nil
+	}
+	files := make([]repoFile, 0)
+	err := filepath.WalkDir(m.commit.stagingDir, func(stagedPath string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && stagedPath == m.commit.stagingDir {
+			return filepath.SkipDir
+		} else if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(m.commit.stagingDir, stagedPath)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(stagedPath)
+		if err != nil {
+			return err
+		}
+		files = append(files, repoFile{path: filepath.ToSlash(rel), content: content})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read staged media: %w", err)
+	}
+	if len(files) > 0 {
+		m.logger.Printf("found %d staged media files from a previous run", len(files))
+	}
+	m.restage(files)
+	return nil
+}
+
+// stagedPath returns the path a staged file is kept at in the staging directory.
+func (m *micropubRepoStore) stagedPath(path string) (string, error) {
+	if m.commit.stagingDir == "" {
+		return "", fmt.Errorf("staging files requires a staging directory")
+	}
+	stagedPath := filepath.Join(m.commit.stagingDir, filepath.FromSlash(path))
+	if !strings.HasPrefix(stagedPath, filepath.Clean(m.commit.stagingDir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid media path %s", path)
+	}
+	return stagedPath, nil
+}
+
+// takeStaged removes the staged files that are used by one of the files from the queue and returns them.
+// A staged file is used if its name appears in the content, the names of media files are random.
+// If files is nil, all staged files are returned.
+func (m *micropubRepoStore) takeStaged(files []repoFile) []repoFile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	taken := make([]repoFile, 0)
+	remaining := make([]repoFile, 0, len(m.staged))
+	for _, staged := range m.staged {
+		if files == nil || usesFile(files, staged.path) {
+			taken = append(taken, staged)
+		} else {
+			remaining = append(remaining, staged)
+		}
+	}
+	m.staged = remaining
+	if len(m.staged) == 0 && m.stageTimer != nil {
+		m.stageTimer.Stop()
+		m.stageTimer = nil
+	}
+	return taken
+}
+
+// usesFile reports whether the name of the file at path appears in the content of one of the files.
+func usesFile(files []repoFile, filePath string) bool {
+	name := path.Base(filePath)
+	for _, file := range files {
+		if bytes.Contains(file.content, []byte(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// restage puts the files back into the queue after a failed commit.
+func (m *micropubRepoStore) restage(files []repoFile) {
+	if len(files) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.staged = append(files, m.staged...)
+	if m.stageTimer == nil {
+		m.stageTimer = time.AfterFunc(stagedMediaDelay, m.commitStaged)
+	}
+}
+
+// unstage removes the committed files from the staging directory.
+func (m *micropubRepoStore) unstage(files []repoFile) {
+	for _, file := range files {
+		stagedPath, err := m.stagedPath(file.path)
+		if err == nil {
+			err = os.Remove(stagedPath)
+		}
+		if err != nil {
+			m.logger.Printf("unable to remove committed media %s from the staging directory: %v", file.path, err)
+		}
+	}
+}
+
+// commitStaged commits the staged files that were not used by a post.
+func (m *micropubRepoStore) commitStaged() {
+	m.mu.Lock()
+	m.stageTimer = nil
+	m.mu.Unlock()
+	err := m.retry(func() error {
+		m.mu.Lock()
+		empty := len(m.staged) == 0
+		m.mu.Unlock()
+		if empty {
+			return nil
+		}
+		head, err := m.head()
+		if err != nil {
+			return err
+		}
+		return m.writeFiles(head, "add media", nil)
+	})
+	if err != nil {
+		m.logger.Printf("unable to commit staged media: %v", err)
+	}
+}
+
+// commitFiles writes the files in a single commit on top of the parent commit. If the branch
+// has moved away from parent, errConflict is returned.
+func (m *githubContents) commitFiles(branch, parent, message string, files []repoFile) error {
+	var commit struct {
+		Tree struct {
+			Sha string `json:"sha"`
+		} `json:"tree"`
+	}
+	if err := m.api(http.MethodGet, "/git/commits/"+parent, nil, &commit); err != nil {
+		return fmt.Errorf("unable to read commit %s: %w", parent, err)
+	}
+
+	entries := make([]map[string]interface{}, len(files))
+	for i, file := range files {
+		entry := map[string]interface{}{"path": file.path, "mode": "100644", "type": "blob", "sha": nil}
+		if !file.delete {
+			var blob struct {
+				Sha string `json:"sha"`
+			}
+			err := m.api(http.MethodPost, "/git/blobs", map[string]string{
+				"content":  base64.StdEncoding.EncodeToString(file.content),
+				"encoding": "base64",
+			}, &blob)
+			if err != nil {
+				return fmt.Errorf("unable to upload %s: %w", file.path, err)
+			}
+			entry["sha"] = blob.Sha
+		}
+		entries[i] = entry
+	}
+
+	var tree, newCommit struct {
+		Sha string `json:"sha"`
+	}
+	err := m.api(http.MethodPost, "/git/trees", map[string]interface{}{"base_tree": commit.Tree.Sha, "tree": entries}, &tree)
+	if err != nil {
+		return fmt.Errorf("unable to create tree: %w", err)
+	}
+	err = m.api(http.MethodPost, "/git/commits", map[string]interface{}{
+		"message": message,
+		"tree":    tree.Sha,
+		"parents": []string{parent},
+	}, &newCommit)
+	if err != nil {
+		return fmt.Errorf("unable to create commit: %w", err)
+	}
+	// the update is rejected with 422 if it is not a fast forward
+	err = m.api(http.MethodPatch, "/git/refs/heads/"+branch, map[string]interface{}{"sha": newCommit.Sha, "force": false}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to update branch %s: %w", branch, err)
+	}
+	m.logger.Printf("committed %d files to %s: %s", len(files), branch, message)
+	return nil
+}
+
+// targetBranch returns the branch changes are committed to.
+func (m *micropubRepoStore) targetBranch() (string, error) {
+	if m.commit.branch != "" {
+		return m.commit.branch, nil
+	}
+	return m.github.repoDefaultBranch()
+}
+
+// baseBranch returns the branch pull requests are opened against.
+func (m *micropubRepoStore) baseBranch() (string, error) {
+	if m.commit.baseBranch != "" {
+		return m.commit.baseBranch, nil
+	}
+	return m.github.repoDefaultBranch()
+}
+
+// branchCommit returns the sha of the head commit of the branch. If base is not empty,
+// a missing branch is created from the base branch.
+func (m *githubContents) branchCommit(branch, base string) (string, error) {
+	var ref struct {
+		Object struct {
+			Sha string `json:"sha"`
+		} `json:"object"`
+	}
+	err := m.api(http.MethodGet, "/git/ref/heads/"+branch, nil, &ref)
+	if !errors.Is(err, errRepoNotFound) || base == "" {
+		return ref.Object.Sha, err
+	}
+
+	if err := m.api(http.MethodGet, "/git/ref/heads/"+base, nil, &ref); err != nil {
+		return "", fmt.Errorf("unable to read base branch %s: %w", base, err)
+	}
+	err = m.api(http.MethodPost, "/git/refs", map[string]string{"ref": "refs/heads/" + branch, "sha": ref.Object.Sha}, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create branch %s: %w", branch, err)
+	}
+	m.logger.Printf("created branch %s from %s", branch, base)
+	return ref.Object.Sha, nil
+}
+
+// ensurePullRequest opens a pull request from the branch to the base branch, unless one is already open.
+func (m *githubContents) ensurePullRequest(branch, base string) error {
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	query := url.Values{"head": {m.user + ":" + branch}, "base": {base}, "state": {"open"}}
+	if err := m.api(http.MethodGet, "/pulls?"+query.Encode(), nil, &pulls); err != nil {
+		return err
+	}
+	if len(pulls) > 0 {
+		return nil
+	}
+	var pull struct {
+		HtmlUrl string `json:"html_url"`
+	}
+	err := m.api(http.MethodPost, "/pulls", map[string]string{
+		"title": "New micropub posts",
+		"body":  "Posts and media published with micropub.",
+		"head":  branch,
+		"base":  base,
+	}, &pull)
+	if err != nil {
+		return err
+	}
+	m.logger.Printf("opened pull request %s", pull.HtmlUrl)
+	return nil
+}
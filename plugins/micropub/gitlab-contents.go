@@ -0,0 +1,174 @@
+package micropub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// gitlabContents implements repoContents with the repository files api of GitLab.
+type gitlabContents struct {
+	// the url of the instance, without the /api/v4 suffix
+	baseUrl string
+	token   string
+	// the id or the path with namespace of the project, e.g. "group/website"
+	project string
+	client  *http.Client
+	logger  *log.Logger
+
+	// mu guards defaultBranch, which is read on first use
+	mu            sync.Mutex
+	defaultBranch string
+}
+
+func newGitlabContents(baseUrl, token, project string, client *http.Client, logger *log.Logger) *gitlabContents {
+	return &gitlabContents{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+		project: project,
+		client:  client,
+		logger:  logger,
+	}
+}
+
+// getFile returns the decoded content of the file and the id of the last commit that changed it as version.
+// GitLab only checks the last commit id, not the blob id, when a file is updated.
+func (m *gitlabContents) getFile(filePath, ref string) (string, string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	var file struct {
+		Content      string `json:"content"`
+		LastCommitId string `json:"last_commit_id"`
+	}
+	_, err := m.api(http.MethodGet, m.fileUrl(filePath)+"?"+url.Values{"ref": {ref}}.Encode(), nil, &file)
+	if errors.Is(err, errRepoNotFound) {
+		return "", "", errPostNotFound
+	} else if err != nil {
+		return "", "", err
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return "", "", err
+	}
+	return string(content), file.LastCommitId, nil
+}
+
+func (m *gitlabContents) putFile(filePath string, content []byte, sha, branch, message string) error {
+	branch, err := m.branch(branch)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"branch":         branch,
+		"commit_message": message,
+		"content":        base64.StdEncoding.EncodeToString(content),
+		"encoding":       "base64",
+	}
+	// gitlab creates files with POST and updates them with PUT
+	method := http.MethodPost
+	if sha != "" {
+		method = http.MethodPut
+		data["last_commit_id"] = sha
+	}
+	_, err = m.api(method, m.fileUrl(filePath), data, nil)
+	return err
+}
+
+func (m *gitlabContents) deleteFile(filePath, sha, branch, message string) error {
+	branch, err := m.branch(branch)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"branch":         branch,
+		"commit_message": message,
+		"last_commit_id": sha,
+	}
+	_, err = m.api(http.MethodDelete, m.fileUrl(filePath), data, nil)
+	return err
+}
+
+func (m *gitlabContents) listFiles(folder, ref string) ([]repoEntry, error) {
+	folder = folderPrefix(folder)
+	query := url.Values{"recursive": {"true"}, "per_page": {"100"}}
+	if folder != "" {
+		query.Set("path", strings.TrimSuffix(folder, "/"))
+	}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	files := make([]repoEntry, 0)
+	// the next page is returned in the X-Next-Page header, it is empty on the last page
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		var tree []struct {
+			Id   string `json:"id"`
+			Path string `json:"path"`
+			Type string `json:"type"`
+		}
+		header, err := m.api(http.MethodGet, "/repository/tree?"+query.Encode(), nil, &tree)
+		if errors.Is(err, errRepoNotFound) {
+			// the folder does not exist yet
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range tree {
+			if entry.Type == "blob" && strings.HasPrefix(entry.Path, folder) {
+				files = append(files, repoEntry{path: entry.Path, blob: entry.Id})
+			}
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return files, nil
+}
+
+func (m *gitlabContents) fileUrl(filePath string) string {
+	return "/repository/files/" + url.PathEscape(filePath)
+}
+
+// branch returns the branch or the default branch of the project if it is empty, GitLab requires a branch for every write.
+func (m *gitlabContents) branch(branch string) (string, error) {
+	if branch != "" {
+		return branch, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.defaultBranch != "" {
+		return m.defaultBranch, nil
+	}
+
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := m.api(http.MethodGet, "", nil, &project); err != nil {
+		return "", fmt.Errorf("unable to read the default branch of %s: %w", m.project, err)
+	}
+	m.defaultBranch = project.DefaultBranch
+	return project.DefaultBranch, nil
+}
+
+// api sends a request to the project endpoint of the GitLab API and decodes the JSON response into result.
+func (m *gitlabContents) api(method, path string, body, result interface{}) (http.Header, error) {
+	header := http.Header{
+		"Private-Token": {m.token},
+		"Accept":        {"application/json"},
+	}
+	u := m.baseUrl + "/api/v4/projects/" + url.PathEscape(m.project) + path
+	return forgeRequest(m.client, method, u, header, body, result, gitlabStatusError)
+}
+
+// gitlabStatusError returns errConflict if a file is created that already exists, or a file is updated
+// that has been changed since it was read. GitLab responds to both with 400 Bad Request.
+func gitlabStatusError(status int, body []byte) error {
+	if status == http.StatusBadRequest && (strings.Contains(string(body), "already exists") || strings.Contains(string(body), "has changed")) {
+		return fmt.Errorf("%w: status code %d: %s", errConflict, status, string(body))
+	}
+	return statusError(status, body)
+}
@@ -0,0 +1,62 @@
+package micropub
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// UploadedMedia is a file that was saved in the media store.
+type UploadedMedia struct {
+	Url         string    `json:"url"`
+	ContentType string    `json:"mime_type"`
+	Size        int64     `json:"size"`
+	ClientId    string    `json:"client_id,omitempty"`
+	Published   time.Time `json:"published"`
+}
+
+// mediaIndex records the files saved in the media store, so they can be queried from the media endpoint.
+type mediaIndex interface {
+	Add(media UploadedMedia) error
+	// List returns up to limit uploaded files, newest first, after skipping offset files.
+	List(limit, offset int) ([]UploadedMedia, error)
+}
+
+type mediaIndexSQLiteStore struct {
+	db     *sql.DB
+	logger *log.Logger
+}
+
+func newMediaIndexSQLiteStore(db *sql.DB, logger *log.Logger) *mediaIndexSQLiteStore {
+	return &mediaIndexSQLiteStore{db: db, logger: logger}
+}
+
+func (s *mediaIndexSQLiteStore) Add(media UploadedMedia) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO micropub_media (url, content_type, size, client_id, ts) VALUES (?, ?, ?, ?, ?)",
+		media.Url, media.ContentType, media.Size, media.ClientId, media.Published.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *mediaIndexSQLiteStore) List(limit, offset int) ([]UploadedMedia, error) {
+	rows, err := s.db.Query("SELECT url, content_type, size, client_id, ts FROM micropub_media ORDER BY ts DESC, rowid DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := make([]UploadedMedia, 0)
+	for rows.Next() {
+		var m UploadedMedia
+		var ts string
+		err := rows.Scan(&m.Url, &m.ContentType, &m.Size, &m.ClientId, &ts)
+		if err != nil {
+			return nil, err
+		}
+		m.Published, err = time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
@@ -0,0 +1,114 @@
+package micropub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite"
+)
+
+func newTestMediaIndex(t *testing.T) *mediaIndexSQLiteStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migration, err := os.ReadFile("../../model/sqlite-migrations/00011_micropub_media.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := strings.Split(strings.Split(string(migration), "-- +goose Down")[0], "-- +goose Up")[1]
+	if _, err := db.Exec(up); err != nil {
+		t.Fatal(err)
+	}
+	return newMediaIndexSQLiteStore(db, log.New(os.Stdout, "[test] ", log.Flags()))
+}
+
+// newMediaIndexServer returns a conformance server whose api records uploads in a SQLite media index.
+func newMediaIndexServer(t *testing.T) *conformanceServer {
+	gin.SetMode(gin.TestMode)
+	s := &conformanceServer{
+		t:      t,
+		engine: gin.New(),
+		store:  newMemoryStore("https://example.com/posts/"),
+		media:  &memoryMediaStore{files: make(map[string][]byte)},
+	}
+	clientId := func(token string) (string, error) { return "https://app.example.com/", nil }
+	api := newMicropubApiModule(s.store, s.media, newTestMediaIndex(t), nil, nil, nil, stubVerifyToken, clientId, log.New(os.Stdout, "[test] ", log.Flags()))
+	if err := api.RegisterRoutes(s.engine); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *conformanceServer) mediaQuery(query string) *httptest.ResponseRecorder {
+	return s.do(httptest.NewRequest(http.MethodGet, "/micropub/media?"+query, nil), "full-token")
+}
+
+func TestMediaQueries(t *testing.T) {
+	s := newMediaIndexServer(t)
+
+	w := s.mediaQuery("q=last")
+	assertEqual(t, "status of q=last on an empty index", w.Code, 200)
+	assertEqual(t, "q=last on an empty index", strings.TrimSpace(w.Body.String()), `{}`)
+	w = s.mediaQuery("q=source")
+	assertEqual(t, "q=source on an empty index", strings.TrimSpace(w.Body.String()), `{"items":[]}`)
+
+	locations := make([]string, 3)
+	for i, name := range []string{"first.jpg", "second.jpg", "third.jpg"} {
+		w := s.postMultipart("/micropub/media", "full-token", nil, map[string][]string{"file": {name}})
+		if w.Code != 201 {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		locations[i] = w.Header().Get("Location")
+	}
+
+	var last UploadedMedia
+	w = s.mediaQuery("q=last")
+	if err := json.Unmarshal(w.Body.Bytes(), &last); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "url of the last upload", last.Url, locations[2])
+	assertEqual(t, "mime type of the last upload", last.ContentType, "image/jpeg")
+	assertEqual(t, "client of the last upload", last.ClientId, "https://app.example.com/")
+	if last.Size != int64(len("image third.jpg")) || last.Published.IsZero() {
+		t.Errorf("unexpected size or published date %#v", last)
+	}
+
+	source := func(query string) []string {
+		w := s.mediaQuery(query)
+		if w.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var res struct {
+			Items []UploadedMedia `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		urls := make([]string, len(res.Items))
+		for i, item := range res.Items {
+			urls[i] = item.Url
+		}
+		return urls
+	}
+	assertEqual(t, "all uploads", source("q=source"), []string{locations[2], locations[1], locations[0]})
+	assertEqual(t, "limit and offset", source("q=source&limit=1&offset=1"), []string{locations[1]})
+
+	assertEqual(t, "status of an invalid offset", s.mediaQuery("q=source&offset=x").Code, 400)
+	assertEqual(t, "status of an unknown query", s.mediaQuery("q=unknown").Code, 400)
+	w = s.do(httptest.NewRequest(http.MethodGet, "/micropub/media?q=last", nil), "")
+	assertEqual(t, "status without a token", w.Code, 401)
+}
+
+func TestMediaQueriesWithoutIndex(t *testing.T) {
+	s := newConformanceServer(t)
+	assertEqual(t, "status without a media index", s.mediaQuery("q=last").Code, 400)
+}
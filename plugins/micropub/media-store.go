@@ -3,6 +3,8 @@ package micropub
 import (
 	"context"
 	"log"
+
+	"github.com/google/uuid"
 )
 
 type mediaStore interface {
@@ -19,3 +21,45 @@ func (n nopMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (s
 	n.logger.Println("Skipping file: ", file.Name)
 	return "", nil
 }
+
+// saveMedia saves the file in the media store and returns its url,
+// and the urls of all stored sizes if the media store creates variants of images.
+func (m *micropubApiModule) saveMedia(ctx context.Context, file MicropubFile) (string, map[string]string, error) {
+	if store, ok := m.mediaStore.(mediaVariantStore); ok {
+		return store.SaveMediaVariants(ctx, file)
+	}
+	url, err := m.mediaStore.SaveMediaFiles(ctx, file)
+	return url, nil, err
+}
+
+var mediaExtensions = map[string]string{
+	"image/jpeg":      "jpg",
+	"image/png":       "png",
+	"image/gif":       "gif",
+	"image/webp":      "webp",
+	"image/avif":      "avif",
+	"image/svg+xml":   "svg",
+	"video/mp4":       "mp4",
+	"video/webm":      "webm",
+	"video/ogg":       "ogv",
+	"video/quicktime": "mov",
+	"audio/mpeg":      "mp3",
+	"audio/mp4":       "m4a",
+	"audio/aac":       "aac",
+	"audio/ogg":       "ogg",
+	"audio/opus":      "opus",
+	"audio/webm":      "weba",
+	"audio/wav":       "wav",
+	"audio/flac":      "flac",
+}
+
+// mediaFileName returns a new unique file name with an extension matching the mime type.
+func mediaFileName(mimeType string, logger *log.Logger) string {
+	extension, ok := mediaExtensions[mimeType]
+	if !ok {
+		extension = "bin"
+		logger.Println("Unknown mime type for micropub upload: ", mimeType)
+	}
+	id := uuid.New()
+	return id.String() + "." + extension
+}
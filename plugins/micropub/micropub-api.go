@@ -1,26 +1,54 @@
 package micropub
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"strings"
+	"sync"
 	"tiim/go-comment-api/config"
 	"tiim/go-comment-api/lib/mfobjects"
 	"tiim/go-comment-api/plugins/indieauth"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
 )
 
 type micropubApiModule struct {
-	store       micropubStore
-	mediaStore  mediaStore
+	store      micropubStore
+	mediaStore mediaStore
+	// mediaIndex records uploaded files, nil if no media index is configured
+	mediaIndex  mediaIndex
+	syndicators []syndicator
+	// citeFetcher fetches the context of replies, likes, reposts and bookmarks, nil if disabled
+	citeFetcher *citeFetcher
+	// postHandlers are notified about created, updated and deleted posts
+	postHandlers []postevent.Handler
+	// queue holds posts with a future published date, nil if scheduling is disabled
+	queue   postQueue
+	queueMu sync.Mutex
+	// baseUrl is the url indiego is running on, the preview urls of scheduled posts start with it
+	baseUrl     string
+	scheduler   *gocron.Scheduler
 	verifyToken indieauth.TokenVerifier
+	clientId    indieauth.ClientIdResolver
 	logger      *log.Logger
 }
 
-func newMicropubApiModule(store micropubStore, mediaStore mediaStore, verifyToken indieauth.TokenVerifier, logger *log.Logger) *micropubApiModule {
-	return &micropubApiModule{store: store, mediaStore: mediaStore, verifyToken: verifyToken, logger: logger}
+func newMicropubApiModule(store micropubStore, mediaStore mediaStore, mediaIndex mediaIndex, queue postQueue, syndicators []syndicator, citeFetcher *citeFetcher, verifyToken indieauth.TokenVerifier, clientId indieauth.ClientIdResolver, logger *log.Logger) *micropubApiModule {
+	return &micropubApiModule{
+		store:       store,
+		mediaStore:  mediaStore,
+		mediaIndex:  mediaIndex,
+		queue:       queue,
+		syndicators: syndicators,
+		citeFetcher: citeFetcher,
+		verifyToken: verifyToken,
+		clientId:    clientId,
+		logger:      logger,
+	}
 }
 
 func (m *micropubApiModule) Name() string {
@@ -28,6 +56,15 @@ func (m *micropubApiModule) Name() string {
 }
 
 func (m *micropubApiModule) Init(config config.GlobalConfig) error {
+	m.scheduler = config.Scheduler
+	// handlers are collected after all plugins are loaded, so the order of the plugins does not matter
+	for _, iface := range config.Config.GetInterfaces(postevent.HandlerInterface) {
+		handler, ok := iface.(postevent.Handler)
+		if !ok {
+			return fmt.Errorf("interface is not a postevent.Handler: %T", iface)
+		}
+		m.postHandlers = append(m.postHandlers, handler)
+	}
 	return nil
 }
 
@@ -35,10 +72,20 @@ func (m *micropubApiModule) RegisterRoutes(r *gin.Engine) error {
 	r.POST("/micropub", m.micropubEndpoint)
 	r.GET("/micropub", m.queryEndpoint)
 	r.POST("/micropub/media", m.mediaEndpoint)
+	r.GET("/micropub/media", m.mediaQueryEndpoint)
+	if m.queue != nil {
+		r.GET("/micropub/scheduled/:id", m.scheduledPreview)
+	}
 	return nil
 }
 
 func (m *micropubApiModule) Start() error {
+	if m.queue != nil {
+		_, err := m.scheduler.Every(1).Minute().Do(m.publishScheduled)
+		if err != nil {
+			return fmt.Errorf("unable to schedule publishing: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -125,6 +172,8 @@ func extractMultipartFormData(c *gin.Context) (MicropubPostRaw, error) {
 		url = urlForm[0]
 		delete(data, "url")
 	}
+	// authToken only removes the token from the parsed form, not from the multipart values
+	delete(data, "access_token")
 	return MicropubPostRaw{
 		Action:     action,
 		PostTye:    postType,
@@ -136,7 +185,7 @@ func extractMultipartFormData(c *gin.Context) (MicropubPostRaw, error) {
 
 func extractJSONData(c *gin.Context) (MicropubPostRaw, error) {
 	var data MicropubPostRaw
-	err := c.BindJSON(&data)
+	err := c.ShouldBindJSON(&data)
 
 	if err != nil {
 		return MicropubPostRaw{}, err
@@ -166,17 +215,26 @@ func getFiles(files map[string][]*multipart.FileHeader) ([]MicropubFile, error)
 	return mpfiles, nil
 }
 
-func addUrlToPost(mp *MicropubPost, url, name, contentType string, logger *log.Logger) {
-	switch contentType {
-	case "image/jpeg", "image/png", "image/gif":
+func addUrlToPost(mp *MicropubPost, url string, srcset map[string]string, name, contentType string, logger *log.Logger) {
+	switch {
+	case url == "":
+		logger.Println("Media store returned no url for file: ", name)
+	case strings.HasPrefix(contentType, "image/"):
 		mp.Entry.Photos = append(mp.Entry.Photos, mfobjects.MF2Photo{
-			Url: url,
+			Url:    url,
+			Srcset: srcset,
 		})
+	case strings.HasPrefix(contentType, "video/"):
+		mp.Entry.Videos = append(mp.Entry.Videos, url)
+	case strings.HasPrefix(contentType, "audio/"):
+		mp.Entry.Audio = append(mp.Entry.Audio, url)
 	default:
 		logger.Println("Unknown content type to add to MicropubPost: ", contentType)
 	}
 }
 
+var errAmbiguousToken = errors.New("both authorization header and access_token form value are set")
+
 func authToken(c *gin.Context) (string, error) {
 	authHeader := c.Request.Header.Get("Authorization")
 	if authHeader != "" {
@@ -188,7 +246,7 @@ func authToken(c *gin.Context) (string, error) {
 	}
 
 	if authHeader != "" && authForm != "" {
-		return "", fmt.Errorf("both authorization header and access_token form value are set")
+		return "", errAmbiguousToken
 	}
 	if authHeader != "" {
 		return authHeader, nil
@@ -0,0 +1,84 @@
+package micropub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tiim/go-comment-api/plugins/indieauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// error codes of the micropub spec, see https://micropub.spec.indieweb.org/#error-response
+const (
+	errorInvalidRequest    = "invalid_request"
+	errorUnauthorized      = "unauthorized"
+	errorInsufficientScope = "insufficient_scope"
+	// not part of the spec, used for failures of the store or media store
+	errorServerError = "server_error"
+	// not part of the spec, used if the post has been modified concurrently
+	errorConflict = "conflict"
+)
+
+// abortWithError responds with a micropub error response. The error is not added to the
+// context, otherwise the error middleware would write its own response.
+func (m *micropubApiModule) abortWithError(c *gin.Context, status int, code string, err error) {
+	m.logger.Printf("micropub request %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	c.AbortWithStatusJSON(status, gin.H{"error": code, "error_description": err.Error()})
+}
+
+func (m *micropubApiModule) invalidRequest(c *gin.Context, err error) {
+	m.abortWithError(c, 400, errorInvalidRequest, err)
+}
+
+func (m *micropubApiModule) serverError(c *gin.Context, err error) {
+	m.abortWithError(c, 500, errorServerError, err)
+}
+
+// insufficientScope responds with 401 and the scope the client has to request.
+func (m *micropubApiModule) insufficientScope(c *gin.Context, scope string) {
+	m.logger.Printf("micropub request %s %s is missing scope %s", c.Request.Method, c.Request.URL.Path, scope)
+	c.AbortWithStatusJSON(401, gin.H{
+		"error":             errorInsufficientScope,
+		"error_description": fmt.Sprintf("the access token does not have the %s scope", scope),
+		"scope":             scope,
+	})
+}
+
+// authorize verifies the access token of the request and responds with an error if it is
+// missing or invalid. The returned ScopeCheck is nil if the request has been aborted.
+func (m *micropubApiModule) authorize(c *gin.Context, scopes []string) (string, indieauth.ScopeCheck) {
+	token, err := authToken(c)
+	if errors.Is(err, errAmbiguousToken) {
+		m.invalidRequest(c, err)
+		return "", nil
+	} else if err != nil {
+		m.abortWithError(c, 401, errorUnauthorized, err)
+		return "", nil
+	}
+	scopeChecker, err := m.verifyToken(token, scopes)
+	if errors.Is(err, indieauth.ErrInsufficientScope) {
+		m.insufficientScope(c, strings.Join(scopes, " "))
+		return "", nil
+	} else if err != nil {
+		// the token is invalid, expired or revoked, the client has to request a new one
+		m.abortWithError(c, 401, errorUnauthorized, err)
+		return "", nil
+	}
+	return token, scopeChecker
+}
+
+// storeError responds with invalid_request if the post does not exist or has no destination,
+// with conflict if it has been modified concurrently and with server_error otherwise.
+func (m *micropubApiModule) storeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errPostDeleted):
+		m.abortWithError(c, 410, errorInvalidRequest, err)
+	case errors.Is(err, errPostNotFound), errors.Is(err, errNoDestination):
+		m.abortWithError(c, 400, errorInvalidRequest, err)
+	case errors.Is(err, errConflict):
+		m.abortWithError(c, 409, errorConflict, err)
+	default:
+		m.serverError(c, err)
+	}
+}
@@ -1,14 +1,11 @@
 package micropub
 
 import (
-	"fmt"
 	"io"
 	"log"
-	"strings"
 	"tiim/go-comment-api/lib/mfobjects"
 	"time"
 
-	"gopkg.in/yaml.v3"
 	"willnorris.com/go/microformats"
 )
 
@@ -32,10 +29,20 @@ type MicropubPostRaw struct {
 
 type MicropubPost struct {
 	Entry   mfobjects.MF2HEntry `yaml:",inline"`
+	Deleted bool                `yaml:"deleted,omitempty"`
 	RawData *microformats.Data  `yaml:"raw_data,flow,omitempty"`
+	// Extra contains front matter keys that are not part of the post, they are written back unchanged.
+	Extra map[string]interface{} `yaml:"-"`
 }
 
 func ParseMicropubPost(data MicropubPostRaw) MicropubPost {
+	// some clients send the post status as mp-post-status
+	if status, ok := data.Properties["mp-post-status"]; ok {
+		if _, ok := data.Properties["post-status"]; !ok {
+			data.Properties["post-status"] = status
+		}
+		delete(data.Properties, "mp-post-status")
+	}
 	mf := microformats.Data{
 		Items: []*microformats.Microformat{
 			{
@@ -51,32 +58,25 @@ func ParseMicropubPost(data MicropubPostRaw) MicropubPost {
 	if post.Entry.Published.IsZero() {
 		post.Entry.Published = time.Now().UTC()
 	}
+	if !post.Entry.IsDraft() {
+		// only drafts are marked, all other posts are published
+		post.Entry.PostStatus = ""
+	}
 	return post
 }
 
+// ToMarkdown renders the post with the default format.
 func (post *MicropubPost) ToMarkdown() string {
-	frontmatter, err := yaml.Marshal(post)
+	markdown, err := defaultPostFormat.Render(*post)
 	if err != nil {
-		log.Println("Error marshalling raw microformat data to JSON: ", err)
-		frontmatter = []byte("{}")
+		log.Println("Error rendering post: ", err)
 	}
-	return fmt.Sprintf("---\n%s---\n\n%s", frontmatter, post.Entry.Content)
+	return markdown
 }
 
+// PostFromMarkdown parses a post written with the default format.
 func PostFromMarkdown(markdown string) MicropubPost {
-	splits := strings.Split(markdown, "---")
-	if len(splits) < 3 {
-		log.Println("Markdown does not contain frontmatter")
-		return MicropubPost{
-			Entry: mfobjects.MF2HEntry{
-				Content: markdown,
-			},
-			RawData: &microformats.Data{},
-		}
-	}
-	frontmatterStr := splits[1]
-	var frontmatter MicropubPost
-	err := yaml.Unmarshal([]byte(frontmatterStr), &frontmatter)
+	post, err := defaultPostFormat.Parse(markdown)
 	if err != nil {
 		log.Println("Could not parse frontmatter", err)
 		return MicropubPost{
@@ -86,6 +86,5 @@ func PostFromMarkdown(markdown string) MicropubPost {
 			RawData: &microformats.Data{},
 		}
 	}
-
-	return frontmatter
+	return post
 }
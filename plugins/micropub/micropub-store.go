@@ -1,13 +1,81 @@
 package micropub
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
 	"willnorris.com/go/microformats"
 )
 
+var (
+	errPostNotFound = errors.New("post not found")
+	errPostDeleted  = errors.New("post has been deleted")
+	// errConflict is returned if the post has been modified since the version the client has read
+	errConflict = errors.New("post has been modified concurrently")
+)
+
 type micropubStore interface {
 	Create(post MicropubPost) (string, error)
-	Modify(url string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error
+	// Modify updates the post. If version is not empty and the post has been modified
+	// since it had this version, errConflict is returned and the post is not changed.
+	Modify(url, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error
 	Delete(url string) error
 	UnDelete(url string) error
-	Get(url string) (*microformats.Microformat, error)
+	// Get returns the post and its version, which changes whenever the post is modified.
+	Get(url string) (*microformats.Microformat, string, error)
+	// List returns up to limit posts, newest first, after skipping offset posts. Drafts are not listed.
+	// The url property of the returned microformats is set.
+	List(limit, offset int) ([]*microformats.Microformat, error)
+	// Categories returns all distinct categories used by the posts in the store.
+	Categories() ([]string, error)
+}
+
+// postVersion returns the version of a post file, the sha of the git blob of the content.
+// It is the same sha the GitHub API returns for the file.
+func postVersion(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// postsToMicroformats sorts the posts newest first and returns the microformats
+// of up to limit posts after skipping offset posts. Drafts are not listed.
+func postsToMicroformats(posts []MicropubPost, limit, offset int) []*microformats.Microformat {
+	published := make([]MicropubPost, 0, len(posts))
+	for _, post := range posts {
+		if !post.Entry.IsDraft() {
+			published = append(published, post)
+		}
+	}
+	sort.SliceStable(published, func(i, j int) bool {
+		return published[i].Entry.Published.After(published[j].Entry.Published)
+	})
+	mfs := make([]*microformats.Microformat, 0, limit)
+	for i := offset; i < len(published) && len(mfs) < limit; i++ {
+		mfs = append(mfs, published[i].Entry.ToMicroformat())
+	}
+	return mfs
+}
+
+// postCategories returns the sorted distinct categories of the posts. Categories only used by drafts are not listed.
+func postCategories(posts []MicropubPost) []string {
+	set := make(map[string]struct{})
+	for _, post := range posts {
+		if post.Entry.IsDraft() {
+			continue
+		}
+		for _, category := range post.Entry.Category {
+			set[category] = struct{}{}
+		}
+	}
+	categories := make([]string, 0, len(set))
+	for category := range set {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
 }
@@ -0,0 +1,150 @@
+package micropub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the maximum nesting depth of microformats in a request, e.g. a checkin inside a reply
+const maxPropertyDepth = 4
+
+// validateCreate checks the type and the shape of the properties of a create request.
+func validateCreate(data MicropubPostRaw) error {
+	if len(data.PostTye) == 0 {
+		return fmt.Errorf("missing type, expected h-entry")
+	}
+	if data.PostTye[0] != "h-entry" {
+		return fmt.Errorf("unsupported type %s, only h-entry is supported", data.PostTye[0])
+	}
+	return validateProperties(data.Properties, 0)
+}
+
+// validateUpdate checks the url and the shape of the replace, add and delete properties of an update request.
+func validateUpdate(data MicropubPostRaw) error {
+	if data.Url == "" {
+		return fmt.Errorf("missing url of the post to update")
+	}
+	if data.Replace == nil && data.Add == nil && data.Delete == nil {
+		return fmt.Errorf("update must contain at least one of replace, add or delete")
+	}
+	if err := validateProperties(data.Replace, 0); err != nil {
+		return fmt.Errorf("invalid replace: %w", err)
+	}
+	if err := validateProperties(data.Add, 0); err != nil {
+		return fmt.Errorf("invalid add: %w", err)
+	}
+	switch del := data.Delete.(type) {
+	case nil:
+	case []interface{}:
+		// delete whole properties
+		for _, property := range del {
+			if _, ok := property.(string); !ok {
+				return fmt.Errorf("invalid delete: property names must be strings")
+			}
+		}
+	case map[string]interface{}:
+		// delete single values of properties
+		for property, values := range del {
+			values, ok := values.([]interface{})
+			if !ok {
+				return fmt.Errorf("invalid delete: values of %s must be an array", property)
+			}
+			if err := validateValues(property, values, 0); err != nil {
+				return fmt.Errorf("invalid delete: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid delete: must be an array of property names or an object of property values")
+	}
+	return nil
+}
+
+// validateUrlAction checks that a delete or undelete request has the url of the post.
+func validateUrlAction(data MicropubPostRaw) error {
+	if data.Url == "" {
+		return fmt.Errorf("missing url of the post to %s", data.Action)
+	}
+	return nil
+}
+
+func validateProperties(properties map[string][]interface{}, depth int) error {
+	for property, values := range properties {
+		if err := validateValues(property, values, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValues checks that the values of the property are strings, numbers, booleans, objects with an
+// html or value key (e.g. content or photos with alt text) or nested microformats.
+func validateValues(property string, values []interface{}, depth int) error {
+	for _, value := range values {
+		switch value := value.(type) {
+		case string, float64, bool:
+		case map[string]interface{}:
+			if err := validateObject(property, value, depth); err != nil {
+				return err
+			}
+		case nil:
+			return fmt.Errorf("property %s must not contain null", property)
+		case []interface{}:
+			return fmt.Errorf("property %s must not contain nested arrays", property)
+		default:
+			return fmt.Errorf("property %s has a value of unsupported type %T", property, value)
+		}
+	}
+	return nil
+}
+
+func validateObject(property string, value map[string]interface{}, depth int) error {
+	if _, ok := value["type"]; ok {
+		return validateMicroformat(property, value, depth)
+	}
+
+	_, hasHtml := value["html"]
+	_, hasValue := value["value"]
+	if !hasHtml && !hasValue {
+		return fmt.Errorf("property %s contains an object without type, html or value", property)
+	}
+	for _, key := range []string{"html", "value", "alt"} {
+		if v, ok := value[key]; ok {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("%s of property %s must be a string", key, property)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMicroformat checks a nested microformat of the form {"type": ["h-..."], "properties": {...}}.
+func validateMicroformat(property string, value map[string]interface{}, depth int) error {
+	if depth >= maxPropertyDepth {
+		return fmt.Errorf("property %s is nested too deeply", property)
+	}
+	types, ok := value["type"].([]interface{})
+	if !ok || len(types) == 0 {
+		return fmt.Errorf("type of property %s must be a non-empty array", property)
+	}
+	for _, t := range types {
+		t, ok := t.(string)
+		if !ok || !strings.HasPrefix(t, "h-") {
+			return fmt.Errorf("type of property %s must only contain microformat types like h-cite", property)
+		}
+	}
+
+	rawProperties, ok := value["properties"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("property %s must have an object of properties", property)
+	}
+	for nested, values := range rawProperties {
+		values, ok := values.([]interface{})
+		if !ok {
+			return fmt.Errorf("values of %s in property %s must be an array", nested, property)
+		}
+		if err := validateValues(nested, values, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
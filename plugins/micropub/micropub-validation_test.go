@@ -0,0 +1,121 @@
+package micropub
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"tiim/go-comment-api/plugins/indieauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"note", `{"type": ["h-entry"], "properties": {"content": ["Hello"], "category": ["a", "b"]}}`, false},
+		{"html content", `{"type": ["h-entry"], "properties": {"content": [{"html": "<p>Hello</p>"}]}}`, false},
+		{"photo with alt", `{"type": ["h-entry"], "properties": {"photo": [{"value": "https://example.com/1.jpg", "alt": "A cat"}]}}`, false},
+		{"nested cite", `{"type": ["h-entry"], "properties": {"in-reply-to": [{"type": ["h-cite"], "properties": {"url": ["https://example.com/"]}}]}}`, false},
+		{"checkin", `{"type": ["h-entry"], "properties": {"checkin": [{"type": ["h-card"], "properties": {"name": ["Cafe"], "latitude": [47.5]}}]}}`, false},
+		{"unsupported type", `{"type": ["h-event"], "properties": {"name": ["Party"]}}`, true},
+		{"html not a string", `{"type": ["h-entry"], "properties": {"content": [{"html": ["<p>Hello</p>"]}]}}`, true},
+		{"object without value", `{"type": ["h-entry"], "properties": {"content": [{"text": "Hello"}]}}`, true},
+		{"nested array", `{"type": ["h-entry"], "properties": {"category": [["a"]]}}`, true},
+		{"null value", `{"type": ["h-entry"], "properties": {"content": [null]}}`, true},
+		{"nested properties not arrays", `{"type": ["h-entry"], "properties": {"in-reply-to": [{"type": ["h-cite"], "properties": {"url": "https://example.com/"}}]}}`, true},
+		{"update", `{"action": "update", "url": "https://example.com/1", "replace": {"content": ["Hi"]}, "delete": ["category"]}`, false},
+		{"update delete values", `{"action": "update", "url": "https://example.com/1", "delete": {"category": ["a"]}}`, false},
+		{"update without url", `{"action": "update", "replace": {"content": ["Hi"]}}`, true},
+		{"update without changes", `{"action": "update", "url": "https://example.com/1"}`, true},
+		{"update delete string", `{"action": "update", "url": "https://example.com/1", "delete": "category"}`, true},
+		{"update delete values not array", `{"action": "update", "url": "https://example.com/1", "delete": {"category": "a"}}`, true},
+		{"delete without url", `{"action": "delete"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data MicropubPostRaw
+			if err := json.Unmarshal([]byte(tt.body), &data); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			switch data.Action {
+			case "":
+				err = validateCreate(data)
+			case "update":
+				err = validateUpdate(data)
+			default:
+				err = validateUrlAction(data)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMicropubErrorResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verifyToken := func(token string, scopes []string) (indieauth.ScopeCheck, error) {
+		if token == "revoked" {
+			return nil, indieauth.ErrTokenRevoked
+		}
+		if token != "create-only" {
+			return nil, indieauth.ErrInsufficientScope
+		}
+		return func(scope string) bool { return scope == "create" }, nil
+	}
+	store := &createOnlyStore{}
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, nil, nil, nil, verifyToken, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+	r := gin.New()
+	r.POST("/micropub", api.micropubEndpoint)
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"missing token", "", `{"type": ["h-entry"], "properties": {"content": ["Hello"]}}`, 401, errorUnauthorized},
+		{"revoked token", "revoked", `{"type": ["h-entry"], "properties": {"content": ["Hello"]}}`, 401, errorUnauthorized},
+		{"missing scope", "create-only", `{"action": "delete", "url": "https://example.com/posts/1"}`, 401, errorInsufficientScope},
+		{"invalid content", "create-only", `{"type": ["h-entry"], "properties": {"content": [{"text": "Hello"}]}}`, 400, errorInvalidRequest},
+		{"invalid json", "create-only", `{"type": "h-entry"}`, 400, errorInvalidRequest},
+		{"created", "create-only", `{"type": ["h-entry"], "properties": {"name": ["hello"], "content": [{"html": "<p>Hello</p>"}]}}`, 201, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantError == "" {
+				if w.Header().Get("Location") != "https://example.com/posts/hello" {
+					t.Errorf("unexpected location %q", w.Header().Get("Location"))
+				}
+				return
+			}
+			var res map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res["error"] != tt.wantError || res["error_description"] == "" {
+				t.Errorf("unexpected error response %v", res)
+			}
+		})
+	}
+}
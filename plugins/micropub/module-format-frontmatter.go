@@ -0,0 +1,108 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+)
+
+type frontMatterFormatModule struct {
+	Profile        string            `json:"profile"`
+	Fields         map[string]string `json:"fields"`
+	DateFormat     string            `json:"date_format"`
+	IncludeRawData *bool             `json:"include_raw_data"`
+	Syntax         string            `json:"syntax"`
+}
+
+func init() {
+	config.RegisterModule(&frontMatterFormatModule{})
+}
+
+func (m *frontMatterFormatModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.format.frontmatter",
+		New:  func() config.Module { return new(frontMatterFormatModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Front matter format module. Writes micropub posts as markdown files with yaml or toml front matter matching the expectations of a static site generator.`,
+			Fields: map[string]string{
+				"Profile": `The static site generator profile that defines the front matter keys and the date format.
+					One of "indiego" (the default), "hugo", "jekyll" or "eleventy".`,
+				"Fields": `JSON map of micropub property -> front matter key, overriding the keys of the profile. An empty key omits the property.
+					Supported properties: name, summary, published, updated, author, category, photo, video, audio, in-reply-to, rsvp, like-of, repost-of, bookmark-of, checkin, syndication, deleted, post-status (written as true for drafts)
+					and post-type (the discovered post type, e.g. note, article, reply, only written and not set by default).
+					Example <code>{\"category\": \"categories\", \"summary\": \"\"}</code>`,
+				"DateFormat":     `The go time layout of dates in the front matter, overriding the format of the profile. Example "2006-01-02"`,
+				"IncludeRawData": `If true, the raw micropub request is added to the front matter as raw_data. Defaults to true for the indiego profile and false for all others.`,
+				"Syntax": `The syntax of the front matter, "yaml" (the default, delimited by ---) or "toml" (delimited by +++).
+					Existing posts are read in either syntax.`,
+			},
+		},
+	}
+}
+
+func (m *frontMatterFormatModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	profile, err := m.profile()
+	if err != nil {
+		return nil, err
+	}
+	return &frontMatterFormat{profile: profile}, nil
+}
+
+// profile returns the selected profile with the overrides of the module applied.
+func (m *frontMatterFormatModule) profile() (frontMatterProfile, error) {
+	name := m.Profile
+	if name == "" {
+		name = "indiego"
+	}
+	base, ok := frontMatterProfiles[name]
+	if !ok {
+		return frontMatterProfile{}, fmt.Errorf("unknown front matter profile %s", name)
+	}
+
+	profile := frontMatterProfile{
+		keys:           make(map[string]string, len(base.keys)),
+		dateFormat:     base.dateFormat,
+		includeRawData: base.includeRawData,
+		syntax:         "yaml",
+	}
+	for field, key := range base.keys {
+		profile.keys[field] = key
+	}
+	for field, key := range m.Fields {
+		if _, ok := base.keys[field]; !ok {
+			return frontMatterProfile{}, fmt.Errorf("unknown front matter field %s", field)
+		}
+		profile.keys[field] = key
+	}
+	if m.DateFormat != "" {
+		profile.dateFormat = m.DateFormat
+	}
+	if m.IncludeRawData != nil {
+		profile.includeRawData = *m.IncludeRawData
+	}
+	switch m.Syntax {
+	case "", "yaml":
+	case "toml":
+		profile.syntax = "toml"
+	default:
+		return frontMatterProfile{}, fmt.Errorf("unknown front matter syntax %s", m.Syntax)
+	}
+	return profile, nil
+}
+
+// loadPostFormat loads the optional format module in the field of a store module.
+// If no format is configured, the default format is returned.
+func loadPostFormat(global config.GlobalConfig, structPtr any, fieldName string, raw config.ModuleRaw) (postFormat, error) {
+	if raw.Name == "" {
+		return defaultPostFormat, nil
+	}
+	formatInt, err := global.Config.LoadModule(structPtr, fieldName, nil)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := formatInt.(postFormat)
+	if !ok {
+		return nil, fmt.Errorf("format module is not of type micropub.postFormat: %T", formatInt)
+	}
+	return format, nil
+}
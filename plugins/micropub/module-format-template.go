@@ -0,0 +1,66 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"tiim/go-comment-api/config"
+)
+
+type templateFormatModule struct {
+	Template      string            `json:"template"`
+	TemplateFile  string            `json:"template_file"`
+	TypeTemplates map[string]string `json:"type_templates"`
+	Profile       string            `json:"profile"`
+	Fields        map[string]string `json:"fields"`
+	DateFormat    string            `json:"date_format"`
+	Syntax        string            `json:"syntax"`
+}
+
+func init() {
+	config.RegisterModule(&templateFormatModule{})
+}
+
+func (m *templateFormatModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.format.template",
+		New:  func() config.Module { return new(templateFormatModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Template format module. Writes micropub posts with a custom go template (text/template).
+				The template gets the fields .Post, .Entry and .FrontMatter (the front matter of the profile without delimiters) and the function yaml to encode values.
+				Posts are read back with the front matter profile, so the template should contain the front matter keys of the profile to allow updates.
+				Example <code>---\n{{ .FrontMatter }}layout: post\n---\n\n{{ .Entry.Content }}</code>`,
+			Fields: map[string]string{
+				"Template":     `The go template of the post files.`,
+				"TemplateFile": `A file containing the go template, used if Template is empty.`,
+				"TypeTemplates": `JSON map of post type -> go template, overriding the template for posts of the type.
+					Post types: note, article, photo, video, audio, reply, like, repost, bookmark, checkin, rsvp.`,
+				"Profile":    `The front matter profile used for .FrontMatter and to read posts. One of "indiego" (the default), "hugo", "jekyll" or "eleventy".`,
+				"Fields":     `JSON map of micropub property -> front matter key, overriding the keys of the profile. See micropub.format.frontmatter.`,
+				"DateFormat": `The go time layout of dates in the front matter, overriding the format of the profile.`,
+				"Syntax":     `The syntax of .FrontMatter, "yaml" (the default) or "toml". Existing posts are read in either syntax.`,
+			},
+		},
+	}
+}
+
+func (m *templateFormatModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	text := m.Template
+	if text == "" && m.TemplateFile != "" {
+		content, err := os.ReadFile(m.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template file %s: %w", m.TemplateFile, err)
+		}
+		text = string(content)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("template or template file is required")
+	}
+
+	frontMatter := frontMatterFormatModule{Profile: m.Profile, Fields: m.Fields, DateFormat: m.DateFormat, Syntax: m.Syntax}
+	profile, err := frontMatter.profile()
+	if err != nil {
+		return nil, err
+	}
+	return newTemplateFormat(text, m.TypeTemplates, profile)
+}
@@ -0,0 +1,36 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/model"
+)
+
+type mediaIndexSQLiteModule struct{}
+
+func init() {
+	config.RegisterModule(&mediaIndexSQLiteModule{})
+}
+
+func (m *mediaIndexSQLiteModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.media-index.sqlite",
+		New:  func() config.Module { return new(mediaIndexSQLiteModule) },
+		Docs: config.ConfigDocs{
+			DocString: `SQLite media index module. Records all files uploaded through micropub, so that clients can query them with q=last and q=source on the media endpoint. Must be loaded after the store.sqlite module.`,
+		},
+	}
+}
+
+func (m *mediaIndexSQLiteModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	storeInt, err := config.GetModule("store.sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("depends on store.sqlite plugin: %v", err)
+	}
+	store, ok := storeInt.(*model.SQLiteStore)
+	if !ok {
+		return nil, fmt.Errorf("store.sqlite is not a of type model.SQLiteStore: %T", storeInt)
+	}
+	return newMediaIndexSQLiteStore(store.GetDBConnection(), logger), nil
+}
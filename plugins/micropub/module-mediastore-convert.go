@@ -7,8 +7,19 @@ import (
 )
 
 type MediastoreConvertModule struct {
-	FormatMap  map[string]string `json:"format_map"`
-	MediaStore config.ModuleRaw  `json:"media_store" config:"micropub.media-store"`
+	FormatMap      map[string]string `json:"format_map"`
+	MaxWidth       int               `json:"max_width"`
+	MaxHeight      int               `json:"max_height"`
+	Quality        map[string]int    `json:"quality"`
+	Variants       []ImageVariant    `json:"variants"`
+	DefaultVariant string            `json:"default_variant"`
+	MediaStore     config.ModuleRaw  `json:"media_store" config:"micropub.media-store"`
+}
+
+type ImageVariant struct {
+	Name      string `json:"name"`
+	MaxWidth  int    `json:"max_width"`
+	MaxHeight int    `json:"max_height"`
 }
 
 func init() {
@@ -20,7 +31,9 @@ func (m *MediastoreConvertModule) IndieGoModule() config.ModuleInfo {
 		Name: "micropub.media-store.convert",
 		New:  func() config.Module { return new(MediastoreConvertModule) },
 		Docs: config.ConfigDocs{
-			DocString: `Media store moduel that converts files from one media file format into another before passing it to another media store module.`,
+			DocString: `Media store moduel that converts files from one media file format into another before passing it to another media store module.
+				It can also scale images down and store multiple sizes of every image. Converted images are stripped of all metadata, 
+				the EXIF orientation of jpeg images is applied to the image before. Jpeg, png and webp images that are not converted are stored without their EXIF, XMP and text metadata.`,
 			Fields: map[string]string{
 				"FormatMap": `JSON map of source -> destination mime types.
 					Example <code>{\"image/jpeg\": \"image/webp\"}</code>
@@ -28,8 +41,20 @@ func (m *MediastoreConvertModule) IndieGoModule() config.ModuleInfo {
 					The default value is {"*": "-"} which does not convert anything.
 					Currently the following mime types are supported:
 					<ul><li>image/jpeg</li><li>image/png</li><li>image/webp</li><li>image/gif</li></ul>
+					Images in other formats, e.g. svg or avif, are stored unchanged.
 					`,
-				"MediaStore": `The media store module to pass the converted file to.`,
+				"MaxWidth":  `The maximum width of stored images in pixels. Larger images are scaled down. 0 means no limit.`,
+				"MaxHeight": `The maximum height of stored images in pixels. Larger images are scaled down. 0 means no limit.`,
+				"Quality": `JSON map of mime type -> encoding quality (1-100) for lossy formats.
+					Example <code>{\"image/jpeg\": 85, \"image/webp\": 70}</code>
+					Mime types that are not in the map use a quality of 75.`,
+				"Variants": `List of sizes every image is stored in, each variant is passed to the media store separately.
+					Every variant has a "name", a "max_width" and a "max_height" (0 means no limit, MaxWidth and MaxHeight still apply).
+					Example <code>[{\"name\": \"thumbnail\", \"max_width\": 320}, {\"name\": \"medium\", \"max_width\": 1024}, {\"name\": \"full\"}]</code>
+					The urls of all variants are added to the srcset of the photo in the post.
+					If empty, a single image is stored.`,
+				"DefaultVariant": `The name of the variant that is referenced in the post and returned from the media endpoint. Defaults to the largest variant.`,
+				"MediaStore":     `The media store module to pass the converted file to.`,
 			},
 		},
 	}
@@ -40,9 +65,36 @@ func (m *MediastoreConvertModule) Load(config config.GlobalConfig, args interfac
 	if err != nil {
 		return nil, fmt.Errorf("failed to load media store module: %w", err)
 	}
+
+	variants := make([]imageVariant, len(m.Variants))
+	defaultFound := m.DefaultVariant == ""
+	for i, v := range m.Variants {
+		if v.Name == "" {
+			return nil, fmt.Errorf("variant %d has no name", i)
+		}
+		if v.Name == m.DefaultVariant {
+			defaultFound = true
+		}
+		variants[i] = imageVariant{name: v.Name, maxWidth: v.MaxWidth, maxHeight: v.MaxHeight}
+	}
+	if !defaultFound {
+		return nil, fmt.Errorf("default variant %s is not in the list of variants", m.DefaultVariant)
+	}
+
+	for mimeType, quality := range m.Quality {
+		if quality < 1 || quality > 100 {
+			return nil, fmt.Errorf("quality for %s must be between 1 and 100, got %d", mimeType, quality)
+		}
+	}
+
 	return &convertMediaStore{
 		childMediaStore: ms.(mediaStore),
 		convertMap:      m.FormatMap,
+		maxWidth:        m.MaxWidth,
+		maxHeight:       m.MaxHeight,
+		quality:         m.Quality,
+		variants:        variants,
+		defaultVariant:  m.DefaultVariant,
 		logger:          logger,
 	}, nil
 }
@@ -0,0 +1,69 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"tiim/go-comment-api/config"
+)
+
+type MediastoreFilesystemModule struct {
+	// The directory to store the media files in.
+	Directory string `json:"directory"`
+	// The format of the url to the media file:
+	// {name} will be replaced with the name of the file.
+	UrlFormat string `json:"url_format"`
+	// The route to serve the directory on, or an empty string to not serve the files.
+	ServePath string `json:"serve_path"`
+}
+
+func init() {
+	config.RegisterModule(&MediastoreFilesystemModule{})
+}
+
+func (m *MediastoreFilesystemModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.media-store.filesystem",
+		New:  func() config.Module { return new(MediastoreFilesystemModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Filesystem media store module. This media store stores media in a local directory and can optionally serve it.`,
+			Fields: map[string]string{
+				"Directory": "The directory to store the media files in. It is created if it does not exist.",
+				"UrlFormat": `The format of the url to the media file: 
+					{name} will be replaced with the name of the file.
+					Example: https://indiego.example.com/media/{name}`,
+				"ServePath": `The route to serve the media directory on, for example "/media". If empty, the files are not served by IndieGo.`,
+			},
+		},
+	}
+}
+
+func (m *MediastoreFilesystemModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.Directory == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+
+	if m.UrlFormat == "" {
+		return nil, fmt.Errorf("url format is required")
+	}
+
+	if err := os.MkdirAll(m.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory %s: %w", m.Directory, err)
+	}
+
+	servePath := m.ServePath
+	if servePath != "" && !strings.HasPrefix(servePath, "/") {
+		servePath = "/" + servePath
+	}
+
+	return newFilesystemMediaStore(
+		m.Directory,
+		strings.TrimSuffix(servePath, "/"),
+		func(name, contentType string) string {
+			return strings.Replace(m.UrlFormat, "{name}", name, -1)
+		},
+		logger,
+	), nil
+}
@@ -0,0 +1,68 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"tiim/go-comment-api/config"
+)
+
+type MediastoreRepoModule struct {
+	// The name of the store module the files are committed with.
+	Store string `json:"store"`
+	// The folder in the repository to store the media files in.
+	Folder string `json:"folder"`
+	// The url the folder is published at.
+	UrlPrefix string `json:"url_prefix"`
+}
+
+func init() {
+	config.RegisterModule(&MediastoreRepoModule{})
+}
+
+func (m *MediastoreRepoModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.media-store.repo",
+		New:  func() config.Module { return new(MediastoreRepoModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Repository media store module. This media store adds media files to the repository of the post store,
+				they are committed together with the created or updated post that uses them. Files that are not used by a post within two minutes are committed on their own.
+				Until they are committed, the files are kept in the staging directory of the store.
+				Requires a micropub.store.github module with git_data_api enabled, which must be loaded before the media store.`,
+			Fields: map[string]string{
+				"Store":     `The name of the store module to commit the files with. Default "micropub.store.github"`,
+				"Folder":    `The folder in the repository where the media files should be stored. Example "static/media/"`,
+				"UrlPrefix": `The prefix of the url before the file name. Example "https://example.com/media/"`,
+			},
+		},
+	}
+}
+
+func (m *MediastoreRepoModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.UrlPrefix == "" {
+		return nil, fmt.Errorf("url prefix is required")
+	}
+
+	storeName := m.Store
+	if storeName == "" {
+		storeName = "micropub.store.github"
+	}
+	storeInt, err := config.GetModule(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("depends on the %s module: %v", storeName, err)
+	}
+	stager, ok := storeInt.(fileStager)
+	if !ok {
+		return nil, fmt.Errorf("%s can not commit media files: %T", storeName, storeInt)
+	}
+	if store, ok := stager.(*micropubRepoStore); ok && !store.commit.gitData {
+		return nil, fmt.Errorf("%s must have git_data_api enabled", storeName)
+	}
+
+	folder := strings.Trim(m.Folder, "/")
+	if folder != "" {
+		folder += "/"
+	}
+	return newRepoMediaStore(stager, folder, m.UrlPrefix, logger), nil
+}
@@ -0,0 +1,128 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"tiim/go-comment-api/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type MediastoreS3Module struct {
+	// The url of the S3 compatible endpoint, e.g. https://s3.eu-central-1.amazonaws.com.
+	Endpoint string `json:"endpoint"`
+	// The region of the bucket.
+	Region string `json:"region"`
+	// The name of the bucket.
+	BucketName string `json:"bucket"`
+	// Can be a custom prefix or an empty string for no prefix.
+	// Setting a prefix allows multiple uses of the same bucket.
+	Prefix string `json:"prefix"`
+	// Use path style addressing (endpoint/bucket/key) instead of virtual host style (bucket.endpoint/key).
+	PathStyle bool `json:"path_style"`
+	// The canned ACL of the uploaded files, e.g. public-read.
+	ACL string `json:"acl"`
+	// The access key id.
+	AccessKeyId string `json:"access_key_id"`
+	// The secret access key.
+	SecretAccessKey string `json:"secret_access_key"`
+
+	// The format of the url to the media file:
+	// {name} will be replaced with the name of the file,
+	// {prefix} will be replaced with the prefix,
+	// {bucket} will be replaced with the bucket name.
+	UrlFormat string `json:"url_format"`
+}
+
+func init() {
+	config.RegisterModule(&MediastoreS3Module{})
+}
+
+func (m *MediastoreS3Module) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.media-store.s3",
+		New:  func() config.Module { return new(MediastoreS3Module) },
+		Docs: config.ConfigDocs{
+			DocString: `S3 media store module. This media store stores media in any S3 compatible object storage like AWS S3, MinIO, Garage or Cloudflare R2.`,
+			Fields: map[string]string{
+				"Endpoint":        "The url of the S3 compatible endpoint, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000.",
+				"Region":          "The region of the bucket. Can be left empty for most S3 compatible stores other than AWS.",
+				"BucketName":      "The name of the bucket. The bucket must already exist.",
+				"Prefix":          "Can be a custom prefix or an empty string for no prefix. Setting a prefix allows multiple uses of the same bucket.",
+				"PathStyle":       "Use path style addressing (endpoint/bucket/key) instead of virtual host style (bucket.endpoint/key). Required by most self-hosted stores like MinIO and Garage.",
+				"ACL":             "The canned ACL of the uploaded files, e.g. public-read. If empty, no ACL is sent and the bucket default applies.",
+				"AccessKeyId":     "The access key id.",
+				"SecretAccessKey": "The secret access key.",
+				"UrlFormat": `The format of the url to the media file: 
+					{name} will be replaced with the name of the file, 
+					{prefix} will be replaced with the prefix, 
+					{bucket} will be replaced with the bucket name.
+					Example: https://{bucket}.example.com/{prefix}{name}`,
+			},
+		},
+	}
+}
+
+func (m *MediastoreS3Module) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.Endpoint == "" {
+		return nil, fmt.Errorf("endpoint is required")
+	}
+
+	if m.BucketName == "" {
+		return nil, fmt.Errorf("bucket name is required")
+	}
+
+	if m.AccessKeyId == "" || m.SecretAccessKey == "" {
+		return nil, fmt.Errorf("access key id and secret access key are required")
+	}
+
+	client, err := newS3Client(m.Endpoint, m.Region, m.AccessKeyId, m.SecretAccessKey, m.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	return newS3MediaStore(
+		client,
+		m.BucketName,
+		m.Prefix,
+		m.ACL,
+		func(name, contentType, prefix, bucket string) string {
+			url := m.UrlFormat
+			url = strings.Replace(url, "{name}", name, -1)
+			url = strings.Replace(url, "{prefix}", prefix, -1)
+			url = strings.Replace(url, "{bucket}", bucket, -1)
+			return url
+		},
+		logger,
+	), nil
+}
+
+func newS3Client(endpoint, region, accessKeyId, secretAccessKey string, pathStyle bool) (*minio.Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %s, expected an url like https://s3.example.com", endpoint)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint scheme %s, expected http or https", u.Scheme)
+	}
+
+	lookup := minio.BucketLookupDNS
+	if pathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKeyId, secretAccessKey, ""),
+		Secure:       u.Scheme == "https",
+		Region:       region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create s3 client: %w", err)
+	}
+	return client, nil
+}
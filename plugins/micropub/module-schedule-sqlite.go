@@ -0,0 +1,36 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/model"
+)
+
+type scheduleSQLiteModule struct{}
+
+func init() {
+	config.RegisterModule(&scheduleSQLiteModule{})
+}
+
+func (m *scheduleSQLiteModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.schedule.sqlite",
+		New:  func() config.Module { return new(scheduleSQLiteModule) },
+		Docs: config.ConfigDocs{
+			DocString: `SQLite schedule module. Posts with a published date in the future are kept in the database and written to the store when the date is reached. Must be loaded after the store.sqlite module.`,
+		},
+	}
+}
+
+func (m *scheduleSQLiteModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	storeInt, err := config.GetModule("store.sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("depends on store.sqlite plugin: %v", err)
+	}
+	store, ok := storeInt.(*model.SQLiteStore)
+	if !ok {
+		return nil, fmt.Errorf("store.sqlite is not a of type model.SQLiteStore: %T", storeInt)
+	}
+	return newPostQueueSQLiteStore(store.GetDBConnection(), logger), nil
+}
@@ -0,0 +1,92 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"tiim/go-comment-api/config"
+)
+
+type filesystemStoreModule struct {
+	Directory         string            `json:"directory"`
+	Folder            string            `json:"folder"`
+	TrashFolder       string            `json:"trash_folder"`
+	PathTemplate      string            `json:"path_template"`
+	TypePathTemplates map[string]string `json:"type_path_templates"`
+	Format            config.ModuleRaw  `json:"format" config:"micropub.format"`
+	UrlPrefix         string            `json:"url_prefix"`
+	UrlSuffix         string            `json:"url_suffix"`
+}
+
+func init() {
+	config.RegisterModule(&filesystemStoreModule{})
+}
+
+func (m *filesystemStoreModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.store.filesystem",
+		New:  func() config.Module { return new(filesystemStoreModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Filesystem store module. This module stores micropub entries as markdown files in a local directory, for example a checked out git repository of your website.`,
+			Fields: map[string]string{
+				"Directory":    "The directory all other paths are relative to. Example \"/srv/website\"",
+				"Folder":       `The folder inside the directory where the files should be stored. Example "content/posts"`,
+				"TrashFolder":  `The folder inside the directory where deleted posts are moved to. Posts in the trash can be restored with the undelete action. If empty, posts are deleted permanently.`,
+				"PathTemplate": `The template for the paths of new posts inside the folder, without file extension. Supported placeholders: {year}, {month}, {day} of the published date, {slug} from mp-slug, the name or the first words of the content, {random} for 6 random characters and {type} for the post type (e.g. note, article, reply, photo). If a path is already taken, a counter is appended. Default "{year}/{month}/{random}"`,
+				"TypePathTemplates": `JSON map of post type -> path template, overriding the path template for posts of the type.
+					Post types: note, article, photo, video, audio, reply, like, repost, bookmark, checkin, rsvp. Example <code>{\"like\": \"likes/{year}/{random}\"}</code>`,
+				"Format":    `The format module that defines how posts are written to the files. Defaults to the indiego front matter format.`,
+				"UrlPrefix": `The prefix of the url before the filename. Example "https://example.com/posts/"`,
+				"UrlSuffix": `The suffix of the url after the filename. Example ".html"`,
+			},
+		},
+	}
+}
+
+func (m *filesystemStoreModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.Directory == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+
+	info, err := os.Stat(m.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access directory %s: %w", m.Directory, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", m.Directory)
+	}
+
+	pathTemplates, err := newPostPathTemplates(m.PathTemplate, m.TypePathTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := loadPostFormat(config, m, "Format", m.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := strings.Trim(m.Folder, "/")
+	if folder != "" {
+		folder += "/"
+	}
+
+	mapper := &suffixPrefixUrlMapper{
+		urlPrefix: m.UrlPrefix,
+		urlSuffix: m.UrlSuffix,
+		folder:    folder,
+		extension: ".md",
+	}
+
+	return newMicropubFilesystemStore(
+		m.Directory,
+		folder,
+		strings.Trim(m.TrashFolder, "/"),
+		pathTemplates,
+		format,
+		mapper,
+		logger,
+	), nil
+}
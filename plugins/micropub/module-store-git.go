@@ -0,0 +1,134 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/plugins/indieauth"
+)
+
+type gitStoreModule struct {
+	Directory         string            `json:"directory"`
+	Folder            string            `json:"folder"`
+	TrashFolder       string            `json:"trash_folder"`
+	PathTemplate      string            `json:"path_template"`
+	TypePathTemplates map[string]string `json:"type_path_templates"`
+	Format            config.ModuleRaw  `json:"format" config:"micropub.format"`
+	UrlPrefix         string            `json:"url_prefix"`
+	UrlSuffix         string            `json:"url_suffix"`
+	Remote            string            `json:"remote"`
+	Branch            string            `json:"branch"`
+	AuthorName        string            `json:"author_name"`
+	AuthorEmail       string            `json:"author_email"`
+}
+
+func init() {
+	config.RegisterModule(&gitStoreModule{})
+}
+
+func (m *gitStoreModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.store.git",
+		New:  func() config.Module { return new(gitStoreModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Git store module. This module stores micropub entries as markdown files in a local git working copy and creates a commit for every change. Requires the git binary to be installed.`,
+			Fields: map[string]string{
+				"Directory":    "The directory of the git working copy. All other paths are relative to it. Example \"/srv/website\"",
+				"Folder":       `The folder inside the working copy where the files should be stored. Example "content/posts"`,
+				"TrashFolder":  `The folder inside the working copy where deleted posts are moved to. Posts in the trash can be restored with the undelete action. If empty, posts are deleted permanently.`,
+				"PathTemplate": `The template for the paths of new posts inside the folder, without file extension. Supported placeholders: {year}, {month}, {day} of the published date, {slug} from mp-slug, the name or the first words of the content, {random} for 6 random characters and {type} for the post type (e.g. note, article, reply, photo). If a path is already taken, a counter is appended. Default "{year}/{month}/{random}"`,
+				"TypePathTemplates": `JSON map of post type -> path template, overriding the path template for posts of the type.
+					Post types: note, article, photo, video, audio, reply, like, repost, bookmark, checkin, rsvp. Example <code>{\"like\": \"likes/{year}/{random}\"}</code>`,
+				"Format":      `The format module that defines how posts are written to the files. Defaults to the indiego front matter format.`,
+				"UrlPrefix":   `The prefix of the url before the filename. Example "https://example.com/posts/"`,
+				"UrlSuffix":   `The suffix of the url after the filename. Example ".html"`,
+				"Remote":      `The name or url of the remote to push to after every commit. If empty, commits are not pushed. Example "origin"`,
+				"Branch":      `The branch on the remote to push to. If empty, the current branch is pushed to its upstream.`,
+				"AuthorName":  `The name of the commit author. Defaults to the "me" url of the indieauth plugin.`,
+				"AuthorEmail": `The email of the commit author. Defaults to micropub@{host of the "me" url}.`,
+			},
+		},
+	}
+}
+
+func (m *gitStoreModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.Directory == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git binary not found: %w", err)
+	}
+
+	info, err := os.Stat(m.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access directory %s: %w", m.Directory, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", m.Directory)
+	}
+
+	if m.AuthorName == "" || m.AuthorEmail == "" {
+		me := "IndieGo"
+		indieAuthPlugin, err := config.GetModule("indieauth")
+		if err == nil {
+			if indieAuth, ok := indieAuthPlugin.(*indieauth.IndieAuthApiModule); ok && indieAuth.Me() != "" {
+				me = indieAuth.Me()
+			}
+		}
+		if m.AuthorName == "" {
+			m.AuthorName = me
+		}
+		if m.AuthorEmail == "" {
+			host := "localhost"
+			if u, err := url.Parse(me); err == nil && u.Hostname() != "" {
+				host = u.Hostname()
+			}
+			m.AuthorEmail = "micropub@" + host
+		}
+	}
+
+	pathTemplates, err := newPostPathTemplates(m.PathTemplate, m.TypePathTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := loadPostFormat(config, m, "Format", m.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := strings.Trim(m.Folder, "/")
+	if folder != "" {
+		folder += "/"
+	}
+
+	mapper := &suffixPrefixUrlMapper{
+		urlPrefix: m.UrlPrefix,
+		urlSuffix: m.UrlSuffix,
+		folder:    folder,
+		extension: ".md",
+	}
+
+	fs := newMicropubFilesystemStore(
+		m.Directory,
+		folder,
+		strings.Trim(m.TrashFolder, "/"),
+		pathTemplates,
+		format,
+		mapper,
+		logger,
+	)
+	store := newMicropubGitStore(fs, m.Remote, m.Branch, m.AuthorName, m.AuthorEmail, logger)
+
+	if _, err := store.git("rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("%s is not a git working copy: %w", m.Directory, err)
+	}
+
+	return store, nil
+}
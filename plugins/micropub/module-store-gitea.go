@@ -0,0 +1,98 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+)
+
+type giteaStoreModule struct {
+	BaseUrl           string            `json:"base_url"`
+	GiteaToken        string            `json:"gitea_token"`
+	GiteaUser         string            `json:"gitea_user"`
+	GiteaRepo         string            `json:"gitea_repo"`
+	GiteaFolder       string            `json:"gitea_folder"`
+	Branch            string            `json:"branch"`
+	UrlPrefix         string            `json:"url_prefix"`
+	UrlSuffix         string            `json:"url_suffix"`
+	PathTemplate      string            `json:"path_template"`
+	TypePathTemplates map[string]string `json:"type_path_templates"`
+	Format            config.ModuleRaw  `json:"format" config:"micropub.format"`
+	SoftDelete        bool              `json:"soft_delete"`
+}
+
+func init() {
+	config.RegisterModule(&giteaStoreModule{})
+}
+
+func (m *giteaStoreModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.store.gitea",
+		New:  func() config.Module { return new(giteaStoreModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Gitea store module. This module stores micropub entries as markdown files in a repository on a Gitea or Forgejo instance.`,
+			Fields: map[string]string{
+				"BaseUrl":      `The url of the Gitea or Forgejo instance. Example "https://codeberg.org"`,
+				"GiteaToken":   "The access token. Needs to have write access to the repository.",
+				"GiteaUser":    "The user or organization name that owns the repository.",
+				"GiteaRepo":    "The repository name.",
+				"GiteaFolder":  `The folder in the repository where the files should be stored.`,
+				"Branch":       `The branch the posts are committed to. Defaults to the default branch of the repository.`,
+				"UrlPrefix":    `The prefix of the url before the filename. Example "https://example.com/posts/"`,
+				"UrlSuffix":    `The suffix of the url after the filename. Example ".html"`,
+				"PathTemplate": `The template for the paths of new posts inside the folder, without file extension. Supports the same placeholders as the micropub.store.github module. Default "{year}/{month}/{random}"`,
+				"TypePathTemplates": `JSON map of post type -> path template, overriding the path template for posts of the type.
+					Example <code>{\"like\": \"likes/{year}/{random}\"}</code>`,
+				"Format":     `The format module that defines how posts are written to the files. Defaults to the indiego front matter format.`,
+				"SoftDelete": `If true, deleted posts are kept in the repository and marked with "deleted: true" in the front matter, so they can be restored with the undelete action.`,
+			},
+		},
+	}
+}
+
+func (m *giteaStoreModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.BaseUrl == "" {
+		return nil, fmt.Errorf("base url is required")
+	}
+
+	if m.GiteaToken == "" {
+		return nil, fmt.Errorf("gitea token is required")
+	}
+
+	if m.GiteaUser == "" {
+		return nil, fmt.Errorf("gitea user is required")
+	}
+
+	if m.GiteaRepo == "" {
+		return nil, fmt.Errorf("gitea repo is required")
+	}
+
+	pathTemplates, err := newPostPathTemplates(m.PathTemplate, m.TypePathTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := loadPostFormat(config, m, "Format", m.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := &suffixPrefixUrlMapper{
+		urlPrefix: m.UrlPrefix,
+		urlSuffix: m.UrlSuffix,
+		folder:    m.GiteaFolder,
+		extension: ".md",
+	}
+
+	return newMicropubRepoStore(
+		newGiteaContents(m.BaseUrl, m.GiteaToken, m.GiteaUser, m.GiteaRepo, config.HttpClient, logger),
+		m.GiteaFolder,
+		pathTemplates,
+		format,
+		m.SoftDelete,
+		repoCommitOptions{branch: m.Branch},
+		mapper,
+		logger,
+	), nil
+}
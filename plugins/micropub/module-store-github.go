@@ -7,12 +7,22 @@ import (
 )
 
 type githubStoreModule struct {
-	GithubToken  string `json:"github_token"`
-	GithubUser   string `json:"github_user"`
-	GithubRepo   string `json:"github_repo"`
-	GithubFolder string `json:"github_folder"`
-	UrlPrefix    string `json:"url_prefix"`
-	UrlSuffix    string `json:"url_suffix"`
+	ApiUrl            string            `json:"api_url"`
+	GithubToken       string            `json:"github_token"`
+	GithubUser        string            `json:"github_user"`
+	GithubRepo        string            `json:"github_repo"`
+	GithubFolder      string            `json:"github_folder"`
+	UrlPrefix         string            `json:"url_prefix"`
+	UrlSuffix         string            `json:"url_suffix"`
+	PathTemplate      string            `json:"path_template"`
+	TypePathTemplates map[string]string `json:"type_path_templates"`
+	Format            config.ModuleRaw  `json:"format" config:"micropub.format"`
+	SoftDelete        bool              `json:"soft_delete"`
+	Branch            string            `json:"branch"`
+	BaseBranch        string            `json:"base_branch"`
+	GitDataApi        bool              `json:"git_data_api"`
+	PullRequest       bool              `json:"pull_request"`
+	StagingDirectory  string            `json:"staging_directory"`
 }
 
 func init() {
@@ -26,12 +36,25 @@ func (m *githubStoreModule) IndieGoModule() config.ModuleInfo {
 		Docs: config.ConfigDocs{
 			DocString: `Github store module. This module stores micropub entries as markdown files in a Github repository.`,
 			Fields: map[string]string{
+				"ApiUrl":       `The url of the GitHub api. Defaults to "https://api.github.com", for GitHub Enterprise Server use "https://{hostname}/api/v3".`,
 				"GithubToken":  "The github token. Needs to have write access to the repository.",
 				"GithubUser":   "The github user or organization name.",
 				"GithubRepo":   "The github repository name.",
 				"GithubFolder": `The folder in the repository where the files should be stored.`,
 				"UrlPrefix":    `The prefix of the url before the filename. Example "https://example.com/posts/"`,
 				"UrlSuffix":    `The suffix of the url after the filename. Example ".html"`,
+				"PathTemplate": `The template for the paths of new posts inside the github folder, without file extension. Supported placeholders: {year}, {month}, {day} of the published date, {slug} from mp-slug, the name or the first words of the content, {random} for 6 random characters and {type} for the post type (e.g. note, article, reply, photo). If a path is already taken, a counter is appended. Default "{year}/{month}/{random}"`,
+				"TypePathTemplates": `JSON map of post type -> path template, overriding the path template for posts of the type.
+					Post types: note, article, photo, video, audio, reply, like, repost, bookmark, checkin, rsvp. Example <code>{\"like\": \"likes/{year}/{random}\"}</code>`,
+				"Format":     `The format module that defines how posts are written to the files. Defaults to the indiego front matter format.`,
+				"SoftDelete": `If true, deleted posts are kept in the repository and marked with "deleted: true" in the front matter, so they can be restored with the undelete action.`,
+				"Branch":     `The branch the posts are committed to. Defaults to the default branch of the repository. Required if PullRequest is enabled.`,
+				"BaseBranch": `The branch pull requests are opened against. Defaults to the default branch of the repository.`,
+				"GitDataApi": `If true, every change is written in a single commit with the git data api, together with the media files of the micropub.media-store.repo module.
+					The branch is only updated if it has not moved since the change was prepared, otherwise the change is retried.`,
+				"PullRequest": `If true, posts are committed to Branch and a pull request to BaseBranch is opened, instead of committing to the base branch directly.
+					The branch is created from the base branch if it does not exist.`,
+				"StagingDirectory": `The directory media files of the micropub.media-store.repo module are kept in until they are committed, so they are not lost on a restart. Default "./db/micropub-staged-media"`,
 			},
 		},
 	}
@@ -51,6 +74,20 @@ func (m *githubStoreModule) Load(config config.GlobalConfig, args interface{}, l
 		return nil, fmt.Errorf("github repo is required")
 	}
 
+	pathTemplates, err := newPostPathTemplates(m.PathTemplate, m.TypePathTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.PullRequest && (m.Branch == "" || m.Branch == m.BaseBranch) {
+		return nil, fmt.Errorf("pull requests require a branch that is not the base branch")
+	}
+
+	format, err := loadPostFormat(config, m, "Format", m.Format)
+	if err != nil {
+		return nil, err
+	}
+
 	mapper := &suffixPrefixUrlMapper{
 		urlPrefix: m.UrlPrefix,
 		urlSuffix: m.UrlSuffix,
@@ -58,13 +95,38 @@ func (m *githubStoreModule) Load(config config.GlobalConfig, args interface{}, l
 		extension: ".md",
 	}
 
-	return newMicropubGithubStore(
-		m.GithubToken,
-		m.GithubUser,
-		m.GithubRepo,
+	apiUrl := m.ApiUrl
+	if apiUrl == "" {
+		apiUrl = "https://api.github.com"
+	}
+	contents := newGithubContents(apiUrl, m.GithubToken, m.GithubUser, m.GithubRepo, config.HttpClient, logger)
+
+	stagingDir := m.StagingDirectory
+	if stagingDir == "" {
+		stagingDir = "./db/micropub-staged-media"
+	}
+
+	store := newMicropubRepoStore(
+		contents,
 		m.GithubFolder,
+		pathTemplates,
+		format,
+		m.SoftDelete,
+		repoCommitOptions{
+			branch:      m.Branch,
+			baseBranch:  m.BaseBranch,
+			gitData:     m.GitDataApi,
+			pullRequest: m.PullRequest,
+			stagingDir:  stagingDir,
+		},
 		mapper,
-		config.HttpClient,
 		logger,
-	), nil
+	)
+	if m.GitDataApi {
+		// media staged before a restart is committed with the next post that uses it, or on its own
+		if err := store.loadStaged(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
 }
@@ -0,0 +1,93 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+)
+
+type gitlabStoreModule struct {
+	BaseUrl           string            `json:"base_url"`
+	GitlabToken       string            `json:"gitlab_token"`
+	GitlabProject     string            `json:"gitlab_project"`
+	GitlabFolder      string            `json:"gitlab_folder"`
+	Branch            string            `json:"branch"`
+	UrlPrefix         string            `json:"url_prefix"`
+	UrlSuffix         string            `json:"url_suffix"`
+	PathTemplate      string            `json:"path_template"`
+	TypePathTemplates map[string]string `json:"type_path_templates"`
+	Format            config.ModuleRaw  `json:"format" config:"micropub.format"`
+	SoftDelete        bool              `json:"soft_delete"`
+}
+
+func init() {
+	config.RegisterModule(&gitlabStoreModule{})
+}
+
+func (m *gitlabStoreModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.store.gitlab",
+		New:  func() config.Module { return new(gitlabStoreModule) },
+		Docs: config.ConfigDocs{
+			DocString: `GitLab store module. This module stores micropub entries as markdown files in a GitLab project.`,
+			Fields: map[string]string{
+				"BaseUrl":       `The url of the GitLab instance. Default "https://gitlab.com"`,
+				"GitlabToken":   "The personal, group or project access token. Needs the api scope and write access to the repository.",
+				"GitlabProject": `The id or the path with namespace of the project. Example "group/website"`,
+				"GitlabFolder":  `The folder in the repository where the files should be stored.`,
+				"Branch":        `The branch the posts are committed to. Defaults to the default branch of the project.`,
+				"UrlPrefix":     `The prefix of the url before the filename. Example "https://example.com/posts/"`,
+				"UrlSuffix":     `The suffix of the url after the filename. Example ".html"`,
+				"PathTemplate":  `The template for the paths of new posts inside the folder, without file extension. Supports the same placeholders as the micropub.store.github module. Default "{year}/{month}/{random}"`,
+				"TypePathTemplates": `JSON map of post type -> path template, overriding the path template for posts of the type.
+					Example <code>{\"like\": \"likes/{year}/{random}\"}</code>`,
+				"Format":     `The format module that defines how posts are written to the files. Defaults to the indiego front matter format.`,
+				"SoftDelete": `If true, deleted posts are kept in the repository and marked with "deleted: true" in the front matter, so they can be restored with the undelete action.`,
+			},
+		},
+	}
+}
+
+func (m *gitlabStoreModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+
+	if m.GitlabToken == "" {
+		return nil, fmt.Errorf("gitlab token is required")
+	}
+
+	if m.GitlabProject == "" {
+		return nil, fmt.Errorf("gitlab project is required")
+	}
+
+	baseUrl := m.BaseUrl
+	if baseUrl == "" {
+		baseUrl = "https://gitlab.com"
+	}
+
+	pathTemplates, err := newPostPathTemplates(m.PathTemplate, m.TypePathTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := loadPostFormat(config, m, "Format", m.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := &suffixPrefixUrlMapper{
+		urlPrefix: m.UrlPrefix,
+		urlSuffix: m.UrlSuffix,
+		folder:    m.GitlabFolder,
+		extension: ".md",
+	}
+
+	return newMicropubRepoStore(
+		newGitlabContents(baseUrl, m.GitlabToken, m.GitlabProject, config.HttpClient, logger),
+		m.GitlabFolder,
+		pathTemplates,
+		format,
+		m.SoftDelete,
+		repoCommitOptions{branch: m.Branch},
+		mapper,
+		logger,
+	), nil
+}
@@ -0,0 +1,94 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+)
+
+type storeRouterModule struct {
+	Destinations []storeRouterDestination `json:"destinations"`
+}
+
+type storeRouterDestination struct {
+	Uid        string            `json:"uid"`
+	Name       string            `json:"name"`
+	Store      config.ModuleRaw  `json:"store" config:"micropub.store"`
+	PostTypes  []string          `json:"post_types"`
+	Channels   []string          `json:"channels"`
+	Categories []string          `json:"categories"`
+	Properties map[string]string `json:"properties"`
+}
+
+func init() {
+	config.RegisterModule(&storeRouterModule{})
+}
+
+func (m *storeRouterModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.store.router",
+		New:  func() config.Module { return new(storeRouterModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Store router module. This module writes posts to one of several stores, for example notes and articles to different folders or repositories. The destinations are advertised to micropub clients in the q=config and q=destination queries, clients can select one with the mp-destination property. Otherwise new posts are written to the first destination whose conditions all match the post. Updates and deletes are sent to the destination that contains the post.`,
+			Fields: map[string]string{
+				"Destinations": `The destinations in the order they are matched. Each destination has the fields:
+					<ul>
+					<li><code>uid</code> and <code>name</code>: shown to micropub clients, the name defaults to the uid.</li>
+					<li><code>store</code>: the <a href="#micropub.store"><code>micropub.store</code></a> module of the destination.</li>
+					<li><code>post_types</code>: the post types of the destination, e.g. note, article, photo, reply, like.</li>
+					<li><code>channels</code>: the channels of the destination, matched against the mp-channel property.</li>
+					<li><code>categories</code>: posts with any of the categories are written to the destination.</li>
+					<li><code>properties</code>: JSON map of property -> value, a post must have the value in the property. An empty value matches all posts with the property.</li>
+					</ul>
+					A destination without conditions matches all posts and should be the last one.`,
+			},
+		},
+	}
+}
+
+func (m *storeRouterModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	if len(m.Destinations) == 0 {
+		return nil, fmt.Errorf("at least one destination is required")
+	}
+
+	routes := make([]storeRoute, len(m.Destinations))
+	uids := make(map[string]bool)
+	for i := range m.Destinations {
+		d := &m.Destinations[i]
+		if d.Uid == "" {
+			return nil, fmt.Errorf("destination %d has no uid", i)
+		}
+		if uids[d.Uid] {
+			return nil, fmt.Errorf("duplicate destination uid %s", d.Uid)
+		}
+		uids[d.Uid] = true
+		for _, postType := range d.PostTypes {
+			if !isPostType(postType) {
+				return nil, fmt.Errorf("destination %s has unknown post type %s", d.Uid, postType)
+			}
+		}
+
+		storeInt, err := config.Config.LoadModule(d, "Store", nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the store of destination %s: %w", d.Uid, err)
+		}
+		store, ok := storeInt.(micropubStore)
+		if !ok {
+			return nil, fmt.Errorf("store of destination %s is not of type micropub.micropubStore: %T", d.Uid, storeInt)
+		}
+
+		name := d.Name
+		if name == "" {
+			name = d.Uid
+		}
+		routes[i] = storeRoute{
+			destination: Destination{Uid: d.Uid, Name: name},
+			store:       store,
+			postTypes:   d.PostTypes,
+			channels:    d.Channels,
+			categories:  d.Categories,
+			properties:  d.Properties,
+		}
+	}
+	return newStoreRouter(routes), nil
+}
@@ -0,0 +1,56 @@
+package micropub
+
+import (
+	"fmt"
+	"log"
+	"tiim/go-comment-api/config"
+)
+
+type syndicatorWebhookModule struct {
+	Uid     string            `json:"uid"`
+	Name    string            `json:"name"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+func init() {
+	config.RegisterModule(&syndicatorWebhookModule{})
+}
+
+func (m *syndicatorWebhookModule) IndieGoModule() config.ModuleInfo {
+	return config.ModuleInfo{
+		Name: "micropub.syndicator.webhook",
+		New:  func() config.Module { return new(syndicatorWebhookModule) },
+		Docs: config.ConfigDocs{
+			DocString: `Webhook syndicator module. This syndicator sends new posts as JSON to a http endpoint which is responsible for publishing the post to the syndication target.
+				The request body has the form <code>{"url": "...", "target": "{uid}", "post": {"type": ["h-entry"], "properties": {...}}}</code>.
+				The endpoint must respond with a 2xx status code and either a Location header or a JSON body <code>{"url": "..."}</code> containing the url of the syndicated post.`,
+			Fields: map[string]string{
+				"Uid":     "The unique id of the syndication target, as sent by micropub clients in mp-syndicate-to. Example \"https://twitter.com/username\"",
+				"Name":    "The human readable name of the syndication target shown in micropub clients.",
+				"Url":     "The url of the webhook endpoint.",
+				"Headers": "Additional http headers to send with the request, for example for authorization.",
+			},
+		},
+	}
+}
+
+func (m *syndicatorWebhookModule) Load(config config.GlobalConfig, args interface{}, logger *log.Logger) (config.ModuleInstance, error) {
+	if m.Uid == "" {
+		return nil, fmt.Errorf("uid is required")
+	}
+	if m.Url == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	name := m.Name
+	if name == "" {
+		name = m.Uid
+	}
+	return newWebhookSyndicator(
+		SyndicationTarget{Uid: m.Uid, Name: name},
+		m.Url,
+		m.Headers,
+		config.HttpClient,
+		logger,
+	), nil
+}
@@ -45,6 +45,39 @@ func ModifyEntry(post *MicropubPost, deleteProps interface{}, addProps, replaceP
 	mfData := &microformats.Data{Items: []*microformats.Microformat{mf}}
 	entry := mfobjects.GetHEntry(mfData)
 	entry.Updated = time.Now().UTC()
-	*post = MicropubPost{Entry: entry, RawData: mfData}
+	if post.Entry.IsDraft() && !entry.IsDraft() && !modifiesProperty("published", deleteProps, addProps, replaceProps) {
+		// a promoted draft is published now
+		entry.Published = time.Now().UTC()
+	}
+	if !entry.IsDraft() {
+		entry.PostStatus = ""
+	}
+	if !modifiesProperty("content", deleteProps, addProps, replaceProps) {
+		// keep the markdown body unchanged, the content of the microformat is normalized
+		entry.Content = post.Entry.Content
+	}
+	*post = MicropubPost{Entry: entry, Deleted: post.Deleted, RawData: mfData, Extra: post.Extra}
 	return nil
 }
+
+// modifiesProperty returns true if the update request changes the property.
+func modifiesProperty(property string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) bool {
+	if _, ok := replaceProps[property]; ok {
+		return true
+	}
+	if _, ok := addProps[property]; ok {
+		return true
+	}
+	switch del := deleteProps.(type) {
+	case []interface{}:
+		for _, key := range del {
+			if key == property {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		_, ok := del[property]
+		return ok
+	}
+	return false
+}
@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"log"
 	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/plugins/admin"
 	"tiim/go-comment-api/plugins/indieauth"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
 )
 
 type micropubPlugin struct {
-	StoreData      config.ModuleRaw `json:"store" config:"micropub.store"`
-	MediaStoreData config.ModuleRaw `json:"media_store" config:"micropub.media-store"`
+	StoreData      config.ModuleRaw   `json:"store" config:"micropub.store"`
+	MediaStoreData config.ModuleRaw   `json:"media_store" config:"micropub.media-store"`
+	MediaIndexData config.ModuleRaw   `json:"media_index" config:"micropub.media-index"`
+	ScheduleData   config.ModuleRaw   `json:"schedule" config:"micropub.schedule"`
+	Syndicators    []config.ModuleRaw `json:"syndicate_to" config:"micropub.syndicator"`
+	FetchContext   bool               `json:"fetch_context"`
 }
 
 func init() {
 	config.RegisterModule(&micropubPlugin{})
+	config.RegisterInterface(postevent.HandlerInterface)
 }
 
 func (p *micropubPlugin) IndieGoModule() config.ModuleInfo {
@@ -25,6 +32,10 @@ func (p *micropubPlugin) IndieGoModule() config.ModuleInfo {
 			Fields: map[string]string{
 				"StoreData":      "The store module to use for storing micropub data.",
 				"MediaStoreData": "The media store module to use for storing media.",
+				"MediaIndexData": "Optional media index module that records uploaded files. Required for the q=last and q=source queries of the media endpoint.",
+				"ScheduleData":   "Optional schedule module. If set, posts with a published date in the future are held back and published at that date. Scheduled posts are listed in the admin dashboard, the location returned for them is a preview url that redirects to the post once it is published.",
+				"Syndicators":    "The syndication targets that micropub clients can select with mp-syndicate-to. Posts are sent to the selected targets after they are created.",
+				"FetchContext":   "If true, the pages of in-reply-to, like-of, repost-of and bookmark-of urls are fetched when a post is created, and the name, author, published date and summary of the page are added to the post. Pages that are not fetched within 10 seconds are skipped.",
 			},
 		},
 	}
@@ -50,6 +61,43 @@ func (p *micropubPlugin) Load(config config.GlobalConfig, _ interface{}, logger
 		return nil, fmt.Errorf("media store module is not of type micropub.mediaStore: %T", mstoreInt)
 	}
 
+	var mindex mediaIndex
+	if p.MediaIndexData.Name != "" {
+		mindexInt, err := config.Config.LoadModule(p, "MediaIndexData", nil)
+		if err != nil {
+			return nil, err
+		}
+		mindex, ok = mindexInt.(mediaIndex)
+		if !ok {
+			return nil, fmt.Errorf("media index module is not of type micropub.mediaIndex: %T", mindexInt)
+		}
+	}
+
+	var queue postQueue
+	if p.ScheduleData.Name != "" {
+		queueInt, err := config.Config.LoadModule(p, "ScheduleData", nil)
+		if err != nil {
+			return nil, err
+		}
+		queue, ok = queueInt.(postQueue)
+		if !ok {
+			return nil, fmt.Errorf("schedule module is not of type micropub.postQueue: %T", queueInt)
+		}
+	}
+
+	syndicatorsInt, err := config.Config.LoadModuleSlice(p, "Syndicators", nil)
+	if err != nil {
+		return nil, err
+	}
+	syndicators := make([]syndicator, len(syndicatorsInt))
+	for i, s := range syndicatorsInt {
+		synd, ok := s.(syndicator)
+		if !ok {
+			return nil, fmt.Errorf("syndicator module %d is not of type micropub.syndicator: %T", i, s)
+		}
+		syndicators[i] = synd
+	}
+
 	indieAuthPlugin, err := config.GetModule("indieauth")
 	if err != nil {
 		log.Println("The micropub plugin requires the indieauth plugin to be loaded. If you want to verify a token from another source, please open an issue on github.")
@@ -60,5 +108,26 @@ func (p *micropubPlugin) Load(config config.GlobalConfig, _ interface{}, logger
 		return nil, fmt.Errorf("indieauth plugin is not of type indieauth.IndieAuthApiModule: %T", indieAuthPlugin)
 	}
 
-	return newMicropubApiModule(store, mstore, indieAuth.VerifyToken, logger), nil
+	var fetcher *citeFetcher
+	if p.FetchContext {
+		fetcher = newCiteFetcher(config.HttpClient, logger)
+	}
+
+	api := newMicropubApiModule(store, mstore, mindex, queue, syndicators, fetcher, indieAuth.VerifyToken, indieAuth.ClientId, logger)
+	api.baseUrl = indieAuth.BaseUrl()
+
+	if queue != nil {
+		adminInt, err := config.GetModule("admin")
+		if err == nil {
+			admin, ok := adminInt.(*admin.AdminModule)
+			if !ok {
+				return nil, fmt.Errorf("admin is not a of type admin.AdminModule: %T", adminInt)
+			}
+			admin.RegisterSection(newAdminScheduledSection(api))
+		} else {
+			logger.Printf("admin plugin not loaded, not registering the scheduled posts section")
+		}
+	}
+
+	return api, nil
 }
@@ -0,0 +1,58 @@
+package micropub
+
+import (
+	"regexp"
+	"strings"
+	"tiim/go-comment-api/lib/mfobjects"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
+
+	"willnorris.com/go/microformats"
+)
+
+// contentUrl matches absolute urls in the markdown or html content of a post
+var contentUrl = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+
+// emitPostEvent notifies all post event handlers about the changed post.
+func (m *micropubApiModule) emitPostEvent(kind postevent.Kind, url string, targets []string) {
+	event := postevent.Event{Kind: kind, Url: url, Targets: targets}
+	for _, handler := range m.postHandlers {
+		handler.OnPostEvent(event)
+	}
+}
+
+// emitStoredPostEvent notifies all post event handlers about the changed post, the targets are read from the store.
+func (m *micropubApiModule) emitStoredPostEvent(kind postevent.Kind, url string) {
+	if len(m.postHandlers) == 0 {
+		return
+	}
+	mf, _, err := m.store.Get(url)
+	if err != nil {
+		m.logger.Printf("unable to read post %s for the %s event: %v", url, kind, err)
+		return
+	}
+	entry := mfobjects.GetHEntry(&microformats.Data{Items: []*microformats.Microformat{mf}})
+	if entry.IsDraft() {
+		return
+	}
+	m.emitPostEvent(kind, url, postTargets(entry))
+}
+
+// postTargets returns the urls the post refers to: the urls of the cites and the absolute urls in the content.
+func postTargets(entry mfobjects.MF2HEntry) []string {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(url string) {
+		url = strings.TrimRight(url, ".,;:!?")
+		if url != "" && !seen[url] {
+			seen[url] = true
+			targets = append(targets, url)
+		}
+	}
+	for _, cite := range []mfobjects.MF2HCite{entry.InReplyTo, entry.LikeOf, entry.RepostOf, entry.BookmarkOf} {
+		add(cite.Url)
+	}
+	for _, url := range contentUrl.FindAllString(entry.Content, -1) {
+		add(url)
+	}
+	return targets
+}
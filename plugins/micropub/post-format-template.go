@@ -0,0 +1,83 @@
+package micropub
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"tiim/go-comment-api/lib/mfobjects"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateFormat renders posts with a go template. Posts are parsed with the front matter profile,
+// so the template should write the front matter keys of the profile to allow updates.
+type templateFormat struct {
+	template *template.Template
+	// templates by post type, overriding template
+	types       map[string]*template.Template
+	frontMatter *frontMatterFormat
+}
+
+// postTemplateData is passed to the template of a templateFormat.
+type postTemplateData struct {
+	Post  MicropubPost
+	Entry mfobjects.MF2HEntry
+	// FrontMatter is the front matter of the post according to the profile, without delimiters.
+	FrontMatter string
+}
+
+var postTemplateFuncs = template.FuncMap{
+	// yaml encodes the value as yaml, e.g. {{ yaml .Entry.Name }}
+	"yaml": func(value interface{}) (string, error) {
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(out), "\n"), nil
+	},
+}
+
+// newTemplateFormat parses the template and the templates of post types in types.
+func newTemplateFormat(text string, types map[string]string, profile frontMatterProfile) (*templateFormat, error) {
+	tmpl, err := template.New("post").Funcs(postTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse post template: %w", err)
+	}
+	format := &templateFormat{
+		template:    tmpl,
+		types:       make(map[string]*template.Template, len(types)),
+		frontMatter: &frontMatterFormat{profile: profile},
+	}
+	for postType, typeText := range types {
+		if !isPostType(postType) {
+			return nil, fmt.Errorf("unknown post type %s", postType)
+		}
+		format.types[postType], err = template.New(postType).Funcs(postTemplateFuncs).Parse(typeText)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse post template of %s: %w", postType, err)
+		}
+	}
+	return format, nil
+}
+
+func (f *templateFormat) Render(post MicropubPost) (string, error) {
+	frontMatter, err := f.frontMatter.frontMatter(post)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	tmpl := f.template
+	if typeTemplate, ok := f.types[post.Entry.PostType()]; ok {
+		tmpl = typeTemplate
+	}
+	err = tmpl.Execute(buf, postTemplateData{Post: post, Entry: post.Entry, FrontMatter: frontMatter})
+	if err != nil {
+		return "", fmt.Errorf("unable to render post template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (f *templateFormat) Parse(content string) (MicropubPost, error) {
+	return f.frontMatter.Parse(content)
+}
@@ -0,0 +1,584 @@
+package micropub
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"willnorris.com/go/microformats"
+)
+
+// postFormat converts posts to and from the content of the files in a store.
+type postFormat interface {
+	Render(post MicropubPost) (string, error)
+	Parse(content string) (MicropubPost, error)
+}
+
+// frontMatterProfile describes how the properties of a post are written to the front matter.
+type frontMatterProfile struct {
+	// front matter key by post field name, see postFields. Fields with an empty key are not written.
+	keys map[string]string
+	// the go time layout of dates, an empty string writes yaml timestamps
+	dateFormat string
+	// if true, the raw micropub request is added to the front matter
+	includeRawData bool
+	// the syntax of the front matter, "yaml" or "toml"
+	syntax string
+}
+
+var frontMatterProfiles = map[string]frontMatterProfile{
+	"indiego": {
+		keys: map[string]string{
+			"name":        "name",
+			"summary":     "summary",
+			"published":   "date",
+			"updated":     "modified",
+			"author":      "author",
+			"category":    "content_tags",
+			"photo":       "photos",
+			"video":       "videos",
+			"audio":       "audio",
+			"in-reply-to": "in_reply_to",
+			"rsvp":        "rsvp",
+			"like-of":     "like_of",
+			"repost-of":   "repost_of",
+			"bookmark-of": "bookmark_of",
+			"checkin":     "checkin",
+			"syndication": "syndication",
+			"deleted":     "deleted",
+			"post-status": "draft",
+			"post-type":   "",
+		},
+		includeRawData: true,
+	},
+	"hugo": {
+		keys: map[string]string{
+			"name":        "title",
+			"summary":     "summary",
+			"published":   "date",
+			"updated":     "lastmod",
+			"author":      "author",
+			"category":    "tags",
+			"photo":       "photos",
+			"video":       "videos",
+			"audio":       "audio",
+			"in-reply-to": "in_reply_to",
+			"rsvp":        "rsvp",
+			"like-of":     "like_of",
+			"repost-of":   "repost_of",
+			"bookmark-of": "bookmark_of",
+			"checkin":     "checkin",
+			"syndication": "syndication",
+			"deleted":     "deleted",
+			"post-status": "draft",
+			"post-type":   "",
+		},
+		dateFormat: time.RFC3339,
+	},
+	"jekyll": {
+		keys: map[string]string{
+			"name":        "title",
+			"summary":     "excerpt",
+			"published":   "date",
+			"updated":     "last_modified_at",
+			"author":      "author",
+			"category":    "tags",
+			"photo":       "photos",
+			"video":       "videos",
+			"audio":       "audio",
+			"in-reply-to": "in_reply_to",
+			"rsvp":        "rsvp",
+			"like-of":     "like_of",
+			"repost-of":   "repost_of",
+			"bookmark-of": "bookmark_of",
+			"checkin":     "checkin",
+			"syndication": "syndication",
+			"deleted":     "deleted",
+			"post-status": "draft",
+			"post-type":   "",
+		},
+		dateFormat: "2006-01-02 15:04:05 -0700",
+	},
+	"eleventy": {
+		keys: map[string]string{
+			"name":        "title",
+			"summary":     "description",
+			"published":   "date",
+			"updated":     "updated",
+			"author":      "author",
+			"category":    "tags",
+			"photo":       "photos",
+			"video":       "videos",
+			"audio":       "audio",
+			"in-reply-to": "in_reply_to",
+			"rsvp":        "rsvp",
+			"like-of":     "like_of",
+			"repost-of":   "repost_of",
+			"bookmark-of": "bookmark_of",
+			"checkin":     "checkin",
+			"syndication": "syndication",
+			"deleted":     "deleted",
+			"post-status": "draft",
+			"post-type":   "",
+		},
+		dateFormat: time.RFC3339,
+	},
+}
+
+// defaultPostFormat is used by stores that have no format configured.
+var defaultPostFormat = &frontMatterFormat{profile: frontMatterProfiles["indiego"]}
+
+// postField reads and writes one property of a post from and to the front matter.
+type postField struct {
+	name string
+	// get returns the value to write, or nil if the field is empty
+	get func(post *MicropubPost, dateFormat string) interface{}
+	set func(post *MicropubPost, node *yaml.Node, dateFormat string) error
+}
+
+// postFields are all fields written to the front matter, in order.
+var postFields = []postField{
+	stringField("name", func(p *MicropubPost) *string { return &p.Entry.Name }),
+	stringField("summary", func(p *MicropubPost) *string { return &p.Entry.Summary }),
+	timeField("published", func(p *MicropubPost) *time.Time { return &p.Entry.Published }),
+	timeField("updated", func(p *MicropubPost) *time.Time { return &p.Entry.Updated }),
+	{
+		name: "author",
+		get: func(p *MicropubPost, _ string) interface{} {
+			if p.Entry.Author.Name == "" {
+				return nil
+			}
+			return p.Entry.Author
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(&p.Entry.Author)
+		},
+	},
+	stringSliceField("category", func(p *MicropubPost) *[]string { return &p.Entry.Category }),
+	{
+		name: "photo",
+		get: func(p *MicropubPost, _ string) interface{} {
+			if len(p.Entry.Photos) == 0 {
+				return nil
+			}
+			return p.Entry.Photos
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(&p.Entry.Photos)
+		},
+	},
+	stringSliceField("video", func(p *MicropubPost) *[]string { return &p.Entry.Videos }),
+	stringSliceField("audio", func(p *MicropubPost) *[]string { return &p.Entry.Audio }),
+	citeField("in-reply-to", func(p *MicropubPost) *mfobjects.MF2HCite { return &p.Entry.InReplyTo }),
+	stringField("rsvp", func(p *MicropubPost) *string { return &p.Entry.RSVP }),
+	citeField("like-of", func(p *MicropubPost) *mfobjects.MF2HCite { return &p.Entry.LikeOf }),
+	citeField("repost-of", func(p *MicropubPost) *mfobjects.MF2HCite { return &p.Entry.RepostOf }),
+	citeField("bookmark-of", func(p *MicropubPost) *mfobjects.MF2HCite { return &p.Entry.BookmarkOf }),
+	{
+		name: "checkin",
+		get: func(p *MicropubPost, _ string) interface{} {
+			if p.Entry.Checkin.IsZero() {
+				return nil
+			}
+			return p.Entry.Checkin
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(&p.Entry.Checkin)
+		},
+	},
+	stringSliceField("syndication", func(p *MicropubPost) *[]string { return &p.Entry.Syndication }),
+	{
+		name: "deleted",
+		get: func(p *MicropubPost, _ string) interface{} {
+			if !p.Deleted {
+				return nil
+			}
+			return true
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(&p.Deleted)
+		},
+	},
+	{
+		// drafts are marked with a boolean, as most static site generators expect
+		name: "post-status",
+		get: func(p *MicropubPost, _ string) interface{} {
+			if !p.Entry.IsDraft() {
+				return nil
+			}
+			return true
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			var draft bool
+			if err := node.Decode(&draft); err != nil {
+				return err
+			}
+			if draft {
+				p.Entry.PostStatus = mfobjects.PostStatusDraft
+			}
+			return nil
+		},
+	},
+	{
+		// the post type is derived from the other properties, it is only written for static site generators
+		name: "post-type",
+		get: func(p *MicropubPost, _ string) interface{} {
+			return p.Entry.PostType()
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return nil
+		},
+	},
+}
+
+func stringField(name string, field func(p *MicropubPost) *string) postField {
+	return postField{
+		name: name,
+		get: func(p *MicropubPost, _ string) interface{} {
+			if *field(p) == "" {
+				return nil
+			}
+			return *field(p)
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(field(p))
+		},
+	}
+}
+
+func stringSliceField(name string, field func(p *MicropubPost) *[]string) postField {
+	return postField{
+		name: name,
+		get: func(p *MicropubPost, _ string) interface{} {
+			if len(*field(p)) == 0 {
+				return nil
+			}
+			return *field(p)
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			// some themes allow a single string instead of a list
+			if node.Kind == yaml.ScalarNode {
+				*field(p) = []string{node.Value}
+				return nil
+			}
+			return node.Decode(field(p))
+		},
+	}
+}
+
+func timeField(name string, field func(p *MicropubPost) *time.Time) postField {
+	return postField{
+		name: name,
+		get: func(p *MicropubPost, dateFormat string) interface{} {
+			t := *field(p)
+			if t.IsZero() {
+				return nil
+			}
+			if dateFormat == "" {
+				return t
+			}
+			return t.Format(dateFormat)
+		},
+		set: func(p *MicropubPost, node *yaml.Node, dateFormat string) error {
+			t, err := parseFrontMatterTime(node, dateFormat)
+			if err != nil {
+				return err
+			}
+			*field(p) = t
+			return nil
+		},
+	}
+}
+
+func citeField(name string, field func(p *MicropubPost) *mfobjects.MF2HCite) postField {
+	return postField{
+		name: name,
+		get: func(p *MicropubPost, _ string) interface{} {
+			if field(p).Url == "" {
+				return nil
+			}
+			return *field(p)
+		},
+		set: func(p *MicropubPost, node *yaml.Node, _ string) error {
+			return node.Decode(field(p))
+		},
+	}
+}
+
+// parseFrontMatterTime parses yaml timestamps, times in the date format of the profile and a few common formats.
+func parseFrontMatterTime(node *yaml.Node, dateFormat string) (time.Time, error) {
+	if dateFormat != "" {
+		if t, err := time.Parse(dateFormat, node.Value); err == nil {
+			return t, nil
+		}
+	}
+	var t time.Time
+	if err := node.Decode(&t); err == nil {
+		return t, nil
+	}
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05 -0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, node.Value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse date %q", node.Value)
+}
+
+// frontMatterFormat writes posts as markdown files with yaml or toml front matter.
+type frontMatterFormat struct {
+	profile frontMatterProfile
+}
+
+// frontMatterValue is a key value pair of the front matter.
+type frontMatterValue struct {
+	key   string
+	value interface{}
+}
+
+func (f *frontMatterFormat) Render(post MicropubPost) (string, error) {
+	frontMatter, err := f.frontMatter(post)
+	if err != nil {
+		return "", err
+	}
+	delimiter := "---"
+	if f.profile.syntax == "toml" {
+		delimiter = "+++"
+	}
+	return fmt.Sprintf("%s\n%s%s\n\n%s", delimiter, frontMatter, delimiter, post.Entry.Content), nil
+}
+
+// frontMatter returns the front matter of the post in the syntax of the profile, without delimiters.
+func (f *frontMatterFormat) frontMatter(post MicropubPost) (string, error) {
+	values := make([]frontMatterValue, 0)
+	known := make(map[string]bool)
+	for _, field := range postFields {
+		key := f.profile.keys[field.name]
+		if key == "" {
+			continue
+		}
+		known[key] = true
+		if value := field.get(&post, f.profile.dateFormat); value != nil {
+			values = append(values, frontMatterValue{key, value})
+		}
+	}
+
+	extraKeys := make([]string, 0, len(post.Extra))
+	for key := range post.Extra {
+		if !known[key] && key != "raw_data" {
+			extraKeys = append(extraKeys, key)
+		}
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		values = append(values, frontMatterValue{key, post.Extra[key]})
+	}
+
+	if f.profile.includeRawData && post.RawData != nil {
+		values = append(values, frontMatterValue{"raw_data", post.RawData})
+	}
+
+	if f.profile.syntax == "toml" {
+		return marshalTomlFrontMatter(values)
+	}
+	return marshalYamlFrontMatter(values)
+}
+
+func marshalYamlFrontMatter(values []frontMatterValue) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, v := range values {
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(v.value); err != nil {
+			return "", fmt.Errorf("unable to encode front matter %s: %w", v.key, err)
+		}
+		if v.key == "raw_data" {
+			// keep the raw data compact like the previous versions of the front matter
+			valueNode.Style = yaml.FlowStyle
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v.key}, valueNode)
+	}
+	out, err := yaml.Marshal(mapping)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal front matter: %w", err)
+	}
+	return string(out), nil
+}
+
+// marshalTomlFrontMatter writes the values in order, except that tables are written
+// after all other values as toml requires.
+func marshalTomlFrontMatter(values []frontMatterValue) (string, error) {
+	var plain, tables strings.Builder
+	for _, v := range values {
+		value, err := tomlValue(v.value)
+		if err != nil {
+			return "", fmt.Errorf("unable to encode front matter %s: %w", v.key, err)
+		}
+		out, err := toml.Marshal(map[string]interface{}{v.key: value})
+		if err != nil {
+			return "", fmt.Errorf("unable to encode front matter %s: %w", v.key, err)
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(out), []byte("[")) {
+			tables.Write(out)
+		} else {
+			plain.Write(out)
+		}
+	}
+	return plain.String() + tables.String(), nil
+}
+
+// tomlValue converts the value to basic types with the keys of the yaml tags,
+// so structs are written with the same keys in yaml and toml front matter.
+func tomlValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case string, bool, time.Time, []string:
+		return value, nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := node.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+func (f *frontMatterFormat) Parse(content string) (MicropubPost, error) {
+	frontMatter, body, syntax, ok := splitFrontMatter(content)
+	if !ok {
+		return MicropubPost{Entry: mfobjects.MF2HEntry{Content: content}, RawData: &microformats.Data{}}, nil
+	}
+
+	post := MicropubPost{}
+	post.Entry.Content = body
+
+	var document yaml.Node
+	if syntax == "toml" {
+		// toml is converted to a yaml document, so both syntaxes share the field parsing
+		var values map[string]interface{}
+		if err := toml.Unmarshal([]byte(frontMatter), &values); err != nil {
+			return MicropubPost{}, fmt.Errorf("unable to parse front matter: %w", err)
+		}
+		out, err := yaml.Marshal(normalizeToml(values))
+		if err != nil {
+			return MicropubPost{}, fmt.Errorf("unable to parse front matter: %w", err)
+		}
+		frontMatter = string(out)
+	}
+	if err := yaml.Unmarshal([]byte(frontMatter), &document); err != nil {
+		return MicropubPost{}, fmt.Errorf("unable to parse front matter: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return post, nil
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return MicropubPost{}, fmt.Errorf("front matter is not a mapping")
+	}
+
+	fields := make(map[string]postField, len(postFields))
+	for _, field := range postFields {
+		if key := f.profile.keys[field.name]; key != "" {
+			fields[key] = field
+		}
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i].Value, mapping.Content[i+1]
+		if key == "raw_data" {
+			post.RawData = &microformats.Data{}
+			if err := value.Decode(post.RawData); err != nil {
+				return MicropubPost{}, fmt.Errorf("unable to parse front matter raw_data: %w", err)
+			}
+			continue
+		}
+		field, ok := fields[key]
+		if !ok {
+			// keep unknown keys, e.g. set by the static site generator or by hand, when the post is written again
+			var extra interface{}
+			if err := value.Decode(&extra); err != nil {
+				return MicropubPost{}, fmt.Errorf("unable to parse front matter %s: %w", key, err)
+			}
+			if post.Extra == nil {
+				post.Extra = make(map[string]interface{})
+			}
+			post.Extra[key] = extra
+			continue
+		}
+		if err := field.set(&post, value, f.profile.dateFormat); err != nil {
+			return MicropubPost{}, fmt.Errorf("unable to parse front matter %s: %w", key, err)
+		}
+	}
+	return post, nil
+}
+
+// normalizeToml converts the local date and time types of toml to strings.
+func normalizeToml(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = normalizeToml(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeToml(val)
+		}
+	case toml.LocalDate:
+		return v.String()
+	case toml.LocalDateTime:
+		return v.String()
+	case toml.LocalTime:
+		return v.String()
+	}
+	return value
+}
+
+// splitFrontMatter returns the front matter and the body of the markdown file, and the syntax of the front matter.
+// Yaml front matter is delimited by lines containing only "---" (the closing line can also be "..."),
+// toml front matter by lines containing only "+++". One empty line after the front matter is not part of the body.
+// ok is false if the file has no front matter.
+func splitFrontMatter(markdown string) (frontMatter, body, syntax string, ok bool) {
+	content := strings.TrimPrefix(markdown, "\ufeff")
+	firstEnd := strings.IndexByte(content, '\n')
+	if firstEnd == -1 {
+		return "", markdown, "", false
+	}
+	switch strings.TrimRight(content[:firstEnd], " \t\r") {
+	case "---":
+		syntax = "yaml"
+	case "+++":
+		syntax = "toml"
+	default:
+		return "", markdown, "", false
+	}
+
+	rest := content[firstEnd+1:]
+	for pos := 0; pos < len(rest); {
+		end := strings.IndexByte(rest[pos:], '\n')
+		next := len(rest)
+		if end != -1 {
+			next = pos + end + 1
+		}
+		line := strings.TrimRight(rest[pos:next], " \t\r\n")
+		if (syntax == "yaml" && (line == "---" || line == "...")) || (syntax == "toml" && line == "+++") {
+			body = rest[next:]
+			if strings.HasPrefix(body, "\r\n") {
+				body = body[2:]
+			} else if strings.HasPrefix(body, "\n") {
+				body = body[1:]
+			}
+			return rest[:pos], body, syntax, true
+		}
+		pos = next
+	}
+	// the front matter is not closed
+	return "", markdown, "", false
+}
@@ -0,0 +1,187 @@
+package micropub
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+)
+
+func testPost() MicropubPost {
+	return MicropubPost{
+		Entry: mfobjects.MF2HEntry{
+			Name:      "Hello World",
+			Content:   "Some content\n\nwith paragraphs",
+			Published: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			Category:  []string{"one", "two"},
+			Photos:    mfobjects.MF2Photos{{Url: "https://media.example.com/1.jpg", Alt: "A photo"}},
+			InReplyTo: mfobjects.MF2HCite{Url: "https://example.com/other"},
+		},
+	}
+}
+
+func TestFrontMatterFormatRoundTrip(t *testing.T) {
+	for _, syntax := range []string{"yaml", "toml"} {
+		for name, profile := range frontMatterProfiles {
+			profile.syntax = syntax
+			testFrontMatterRoundTrip(t, name+" "+syntax, &frontMatterFormat{profile: profile})
+		}
+	}
+}
+
+func testFrontMatterRoundTrip(t *testing.T, name string, format *frontMatterFormat) {
+	t.Helper()
+	post := testPost()
+	content, err := format.Render(post)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	got, err := format.Parse(content)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	// the location of parsed dates depends on the date format
+	if !got.Entry.Published.Equal(post.Entry.Published) {
+		t.Errorf("%s: expected published %v, got %v", name, post.Entry.Published, got.Entry.Published)
+	}
+	got.Entry.Published = post.Entry.Published
+	if !reflect.DeepEqual(got.Entry, post.Entry) {
+		t.Errorf("%s: round trip changed the entry\n got %#v\nwant %#v\n%s", name, got.Entry, post.Entry, content)
+	}
+}
+
+func TestFrontMatterFormatHugo(t *testing.T) {
+	format := &frontMatterFormat{profile: frontMatterProfiles["hugo"]}
+	content, err := format.Render(testPost())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"title: Hello World", "date: \"2023-01-02T03:04:05Z\"", "tags:\n    - one"} {
+		if !strings.Contains(content, line) {
+			t.Errorf("expected %q in\n%s", line, content)
+		}
+	}
+	if strings.Contains(content, "raw_data") {
+		t.Errorf("expected no raw_data in\n%s", content)
+	}
+}
+
+func TestTemplateFormat(t *testing.T) {
+	format, err := newTemplateFormat("---\n{{ .FrontMatter }}layout: post\n---\n\n{{ .Entry.Content }}", nil, frontMatterProfiles["jekyll"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := testPost()
+	content, err := format.Render(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "layout: post\n") || !strings.Contains(content, "date: 2023-01-02 03:04:05 +0000\n") {
+		t.Errorf("unexpected template output\n%s", content)
+	}
+	got, err := format.Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Entry.Name != post.Entry.Name || !got.Entry.Published.Equal(post.Entry.Published) || got.Entry.Content != post.Entry.Content {
+		t.Errorf("unexpected parsed post %#v", got.Entry)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		markdown    string
+		frontMatter string
+		body        string
+		syntax      string
+		ok          bool
+	}{
+		{"yaml", "---\ntitle: a\n---\n\nbody", "title: a\n", "body", "yaml", true},
+		{"rule in body", "---\ntitle: a\n---\n\none\n\n---\n\ntwo", "title: a\n", "one\n\n---\n\ntwo", "yaml", true},
+		{"dashes in value", "---\ntitle: a---b\n---\nbody", "title: a---b\n", "body", "yaml", true},
+		{"yaml end marker", "---\ntitle: a\n...\nbody", "title: a\n", "body", "yaml", true},
+		{"toml", "+++\ntitle = 'a'\n+++\n\nbody", "title = 'a'\n", "body", "toml", true},
+		{"crlf", "---\r\ntitle: a\r\n---\r\n\r\nbody", "title: a\r\n", "body", "yaml", true},
+		{"empty", "---\n---\nbody", "", "body", "yaml", true},
+		{"no front matter", "body\n---\n", "", "body\n---\n", "", false},
+		{"rule only", "---\nbody", "", "---\nbody", "", false},
+	}
+	for _, tt := range tests {
+		frontMatter, body, syntax, ok := splitFrontMatter(tt.markdown)
+		if frontMatter != tt.frontMatter || body != tt.body || syntax != tt.syntax || ok != tt.ok {
+			t.Errorf("%s: got (%q, %q, %q, %v), want (%q, %q, %q, %v)", tt.name, frontMatter, body, syntax, ok, tt.frontMatter, tt.body, tt.syntax, tt.ok)
+		}
+	}
+}
+
+func TestFrontMatterFormatExtraKeys(t *testing.T) {
+	format := &frontMatterFormat{profile: frontMatterProfiles["hugo"]}
+	content := "+++\ntitle = 'Hello'\nlayout = 'note'\nweight = 3\n\n[params]\nfeatured = true\n+++\n\nSome content\n\n---\n\nafter a rule\n"
+	post, err := format.Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Entry.Name != "Hello" || post.Entry.Content != "Some content\n\n---\n\nafter a rule\n" {
+		t.Errorf("unexpected post %#v", post.Entry)
+	}
+	if post.Extra["layout"] != "note" || post.Extra["weight"] != 3 {
+		t.Errorf("unexpected extra keys %#v", post.Extra)
+	}
+
+	err = ModifyEntry(&post, nil, nil, map[string][]interface{}{"name": {"Changed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := format.Render(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"title: Changed\n", "layout: note\n", "weight: 3\n", "params:\n    featured: true\n", "\nSome content\n\n---\n\nafter a rule\n"} {
+		if !strings.Contains(rendered, line) {
+			t.Errorf("expected %q in\n%s", line, rendered)
+		}
+	}
+}
+
+func TestDraftPost(t *testing.T) {
+	post := ParseMicropubPost(MicropubPostRaw{
+		PostTye:    []string{"h-entry"},
+		Properties: map[string][]interface{}{"content": {"Not done yet"}, "mp-post-status": {"draft"}},
+	})
+	post.Entry.Published = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !post.Entry.IsDraft() {
+		t.Fatalf("expected a draft, got status %q", post.Entry.PostStatus)
+	}
+
+	format := &frontMatterFormat{profile: frontMatterProfiles["hugo"]}
+	content, err := format.Render(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "draft: true\n") {
+		t.Errorf("expected draft: true in\n%s", content)
+	}
+	post, err = format.Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.Entry.IsDraft() {
+		t.Errorf("expected the parsed post to be a draft")
+	}
+	if mfs := postsToMicroformats([]MicropubPost{post}, 10, 0); len(mfs) != 0 {
+		t.Errorf("expected drafts not to be listed, got %d posts", len(mfs))
+	}
+
+	err = ModifyEntry(&post, nil, nil, map[string][]interface{}{"post-status": {"published"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Entry.IsDraft() || post.Entry.PostStatus != "" {
+		t.Errorf("expected a published post, got status %q", post.Entry.PostStatus)
+	}
+	if post.Entry.Published.Year() == 2023 {
+		t.Errorf("expected the published date of a promoted draft to be updated")
+	}
+}
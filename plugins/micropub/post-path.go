@@ -0,0 +1,197 @@
+package micropub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultPathTemplate is the template used when no path template is configured.
+const defaultPathTemplate = "{year}/{month}/{random}"
+
+// the maximum number of paths tried before creating a post fails
+const maxPathAttempts = 100
+
+// the maximum number of words of the content used for a slug
+const maxSlugWords = 6
+
+// the maximum length of a slug in bytes
+const maxSlugLength = 60
+
+var pathPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+var pathPlaceholders = map[string]bool{
+	"{year}":   true,
+	"{month}":  true,
+	"{day}":    true,
+	"{slug}":   true,
+	"{random}": true,
+	"{type}":   true,
+}
+
+// validatePathTemplate returns an error if the template contains unknown placeholders.
+func validatePathTemplate(template string) error {
+	for _, placeholder := range pathPlaceholder.FindAllString(template, -1) {
+		if !pathPlaceholders[placeholder] {
+			return fmt.Errorf("unknown placeholder %s in path template %s", placeholder, template)
+		}
+	}
+	if strings.HasPrefix(template, "/") || strings.HasSuffix(template, "/") {
+		return fmt.Errorf("path template %s must not start or end with a slash", template)
+	}
+	return nil
+}
+
+// postPathTemplates contains the path template of new posts and optional templates for specific post types.
+type postPathTemplates struct {
+	template string
+	// path templates by post type, see mfobjects.PostTypes
+	types map[string]string
+}
+
+// newPostPathTemplates validates the templates, types maps post types to templates overriding the template.
+func newPostPathTemplates(template string, types map[string]string) (postPathTemplates, error) {
+	if err := validatePathTemplate(template); err != nil {
+		return postPathTemplates{}, err
+	}
+	for postType, typeTemplate := range types {
+		if !isPostType(postType) {
+			return postPathTemplates{}, fmt.Errorf("unknown post type %s, expected one of %s", postType, strings.Join(mfobjects.PostTypes, ", "))
+		}
+		if err := validatePathTemplate(typeTemplate); err != nil {
+			return postPathTemplates{}, err
+		}
+	}
+	return postPathTemplates{template: template, types: types}, nil
+}
+
+// forPost returns the path template for the type of the post.
+func (t postPathTemplates) forPost(post MicropubPost) string {
+	if template, ok := t.types[post.Entry.PostType()]; ok && template != "" {
+		return template
+	}
+	return t.template
+}
+
+func isPostType(postType string) bool {
+	for _, t := range mfobjects.PostTypes {
+		if t == postType {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPostPath returns the path of a new post inside folder, without file extension.
+// The path is generated from the template, see validatePathTemplate for the supported placeholders.
+// If exists reports that a path is already taken, a new random string is generated
+// or, if the template has no {random} placeholder, a counter is appended to the path.
+func nextPostPath(folder, template string, post MicropubPost, rnd *rand.Rand, exists func(path string) (bool, error)) (string, error) {
+	if template == "" {
+		template = defaultPathTemplate
+	}
+	if !strings.HasSuffix(folder, "/") && folder != "" {
+		folder += "/"
+	}
+	published := post.Entry.Published
+	if published.IsZero() {
+		published = time.Now()
+	}
+	slug := postSlug(post)
+
+	for attempt := 1; attempt <= maxPathAttempts; attempt++ {
+		path := pathPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+			switch placeholder {
+			case "{year}":
+				return published.Format("2006")
+			case "{month}":
+				return published.Format("01")
+			case "{day}":
+				return published.Format("02")
+			case "{slug}":
+				if slug == "" {
+					return randomPathString(rnd)
+				}
+				return slug
+			case "{random}":
+				return randomPathString(rnd)
+			case "{type}":
+				return post.Entry.PostType()
+			}
+			return placeholder
+		})
+		if attempt > 1 && !strings.Contains(template, "{random}") {
+			path = fmt.Sprintf("%s-%d", path, attempt)
+		}
+
+		path = folder + path
+		taken, err := exists(path)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("unable to find a free path for the post after %d attempts", maxPathAttempts)
+}
+
+// randomPathString returns a random string of 6 lowercase characters.
+func randomPathString(rnd *rand.Rand) string {
+	return strings.ToLower(base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d", rnd.Int()))))[:6]
+}
+
+// postSlug returns the slug of the post from the mp-slug property, the name or the first words of the content.
+// An empty string is returned if none of them result in a slug.
+func postSlug(post MicropubPost) string {
+	if post.RawData != nil && len(post.RawData.Items) > 0 {
+		if values := post.RawData.Items[0].Properties["mp-slug"]; len(values) > 0 {
+			if s, ok := values[0].(string); ok {
+				if slug := slugify(s); slug != "" {
+					return slug
+				}
+			}
+		}
+	}
+	if slug := slugify(post.Entry.Name); slug != "" {
+		return slug
+	}
+	words := strings.Fields(post.Entry.Content)
+	if len(words) > maxSlugWords {
+		words = words[:maxSlugWords]
+	}
+	return slugify(strings.Join(words, " "))
+}
+
+// slugify converts the string to lowercase letters and digits separated by dashes.
+func slugify(s string) string {
+	// decompose characters so accents can be dropped, e.g. é -> e
+	s = norm.NFKD.String(strings.ToLower(s))
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+		if b.Len() >= maxSlugLength {
+			break
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
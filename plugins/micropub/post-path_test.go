@@ -0,0 +1,107 @@
+package micropub
+
+import (
+	"math/rand"
+	"testing"
+	"tiim/go-comment-api/lib/mfobjects"
+	"time"
+
+	"willnorris.com/go/microformats"
+)
+
+func TestPostSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		post MicropubPost
+		want string
+	}{
+		{
+			name: "mp-slug",
+			post: MicropubPost{RawData: &microformats.Data{Items: []*microformats.Microformat{
+				{Properties: map[string][]interface{}{"mp-slug": {"My Custom Slug"}}},
+			}}},
+			want: "my-custom-slug",
+		},
+		{
+			name: "name",
+			post: MicropubPost{Entry: mfobjects.MF2HEntry{Name: "Über café & more!"}},
+			want: "uber-cafe-more",
+		},
+		{
+			name: "content",
+			post: MicropubPost{Entry: mfobjects.MF2HEntry{Content: "Just had the best coffee in town, would recommend it"}},
+			want: "just-had-the-best-coffee-in",
+		},
+		{
+			name: "empty",
+			post: MicropubPost{Entry: mfobjects.MF2HEntry{Content: "🎉"}},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		if got := postSlug(tt.post); got != tt.want {
+			t.Errorf("%s: expected slug %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNextPostPath(t *testing.T) {
+	post := MicropubPost{Entry: mfobjects.MF2HEntry{Name: "Hello World"}}
+	post.Entry.Published = time.Date(2023, 2, 3, 12, 0, 0, 0, time.UTC)
+	rnd := rand.New(rand.NewSource(1))
+
+	taken := map[string]bool{"posts/2023/02/hello-world": true, "posts/2023/02/hello-world-2": true}
+	exists := func(path string) (bool, error) { return taken[path], nil }
+
+	path, err := nextPostPath("posts", "{year}/{month}/{slug}", post, rnd, exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "posts/2023/02/hello-world-3" {
+		t.Errorf("expected posts/2023/02/hello-world-3, got %s", path)
+	}
+
+	path, err = nextPostPath("", "{year}-{month}-{day}-{random}", post, rnd, exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len("2023-02-03-")+6 || path[:11] != "2023-02-03-" {
+		t.Errorf("unexpected path %s", path)
+	}
+
+	if err := validatePathTemplate("{year}/{title}"); err == nil {
+		t.Errorf("expected an error for an unknown placeholder")
+	}
+}
+
+func TestPostPathTemplatesByType(t *testing.T) {
+	templates, err := newPostPathTemplates("{type}/{slug}", map[string]string{"like": "likes/{year}/{slug}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	exists := func(path string) (bool, error) { return false, nil }
+
+	note := MicropubPost{Entry: mfobjects.MF2HEntry{Content: "Hello World"}}
+	path, err := nextPostPath("", templates.forPost(note), note, rnd, exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "note/hello-world" {
+		t.Errorf("expected note/hello-world, got %s", path)
+	}
+
+	like := MicropubPost{Entry: mfobjects.MF2HEntry{Name: "Liked", LikeOf: mfobjects.MF2HCite{Url: "https://example.com/"}}}
+	like.Entry.Published = time.Date(2023, 2, 3, 12, 0, 0, 0, time.UTC)
+	path, err = nextPostPath("", templates.forPost(like), like, rnd, exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "likes/2023/liked" {
+		t.Errorf("expected likes/2023/liked, got %s", path)
+	}
+
+	if _, err := newPostPathTemplates("", map[string]string{"tweet": "{slug}"}); err == nil {
+		t.Errorf("expected an error for an unknown post type")
+	}
+}
@@ -0,0 +1,118 @@
+package micropub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errRepoNotFound is returned by the api requests of a repository for a 404 response.
+var errRepoNotFound = errors.New("not found in repository")
+
+// repoContents reads and writes the files of a repository with the api of a git forge.
+// Every write creates a commit on the branch, or on the default branch of the repository if branch is empty.
+type repoContents interface {
+	// getFile returns the content and the version of the file at ref, or errPostNotFound if it does not exist.
+	// If ref is empty, the file is read from the default branch.
+	getFile(filePath, ref string) (string, string, error)
+	// putFile creates the file if sha is empty, otherwise it replaces the file if its version is still sha.
+	// errConflict is returned if the file has been changed or created concurrently.
+	putFile(filePath string, content []byte, sha, branch, message string) error
+	// deleteFile deletes the file if its version is still sha.
+	deleteFile(filePath, sha, branch, message string) error
+	// listFiles returns all files in the folder and its subfolders at ref.
+	listFiles(folder, ref string) ([]repoEntry, error)
+}
+
+// repoEntry is a file in the tree of a repository.
+type repoEntry struct {
+	path string
+	// the sha of the blob, it only changes if the content of the file changes
+	blob string
+}
+
+// statusError returns errConflict if the forge rejected a write because the file or branch has changed,
+// and errRepoNotFound for 404. The forges also respond with 422 to invalid requests, which are plain errors.
+func statusError(status int, body []byte) error {
+	switch {
+	case status == http.StatusConflict:
+		return fmt.Errorf("%w: status code %d: %s", errConflict, status, string(body))
+	case status == http.StatusUnprocessableEntity && isStaleWrite(body):
+		return fmt.Errorf("%w: status code %d: %s", errConflict, status, string(body))
+	case status == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errRepoNotFound, string(body))
+	}
+	return fmt.Errorf("unexpected status code %d: %s", status, string(body))
+}
+
+// isStaleWrite reports whether the body of a 422 response says that the sha of a file does not match,
+// that a branch update is not a fast forward because the branch has moved, or that GitHub expected a sha
+// because the file has been created in the meantime.
+func isStaleWrite(body []byte) bool {
+	message := strings.ToLower(strings.ReplaceAll(string(body), `\"`, `"`))
+	return strings.Contains(message, "does not match") || strings.Contains(message, "not a fast forward") ||
+		strings.Contains(message, `"sha" wasn't supplied`)
+}
+
+// folderPrefix returns the folder with a trailing slash, or an empty string for the root of the repository.
+func folderPrefix(folder string) string {
+	folder = strings.Trim(folder, "/")
+	if folder != "" {
+		folder += "/"
+	}
+	return folder
+}
+
+// forgeRequest sends a JSON request to the api of a forge and decodes the JSON response into result, if it is not nil.
+// The error of a response with a status code other than 2xx is created by statusErr.
+func forgeRequest(client *http.Client, method, rawUrl string, header http.Header, body, result interface{}, statusErr func(int, []byte) error) (http.Header, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	req := http.Request{
+		Method: method,
+		URL:    u,
+		Header: header,
+	}
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Body = io.NopCloser(bytes.NewBuffer(buf))
+	}
+	res, err := client.Do(&req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, statusErr(res.StatusCode, data)
+	}
+	if result != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, err
+		}
+	}
+	return res.Header, nil
+}
+
+// escapePath escapes every segment of the file path for use in a url, keeping the slashes.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,535 @@
+package micropub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeForge is a repository with a single branch "main". Every write is a commit, the version
+// of a file is its blob sha for gitea and the last commit that changed it for gitlab.
+type fakeForge struct {
+	files   map[string]string
+	commits map[string]int
+	commit  int
+	// the number of files that have been read
+	reads int
+}
+
+func (f *fakeForge) version(path string, gitlab bool) string {
+	if gitlab {
+		return strconv.Itoa(f.commits[path])
+	}
+	return postVersion([]byte(f.files[path]))
+}
+
+func (f *fakeForge) write(path, content string) {
+	f.commit++
+	f.files[path] = content
+	f.commits[path] = f.commit
+}
+
+func (f *fakeForge) remove(path string) {
+	f.commit++
+	delete(f.files, path)
+	delete(f.commits, path)
+}
+
+// paths returns the paths of all files in the folder, sorted.
+func (f *fakeForge) paths(folder string) []string {
+	paths := make([]string, 0, len(f.files))
+	for path := range f.files {
+		if strings.HasPrefix(path, folder) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (f *fakeForge) encodeFile(w http.ResponseWriter, path string, gitlab bool) {
+	content, ok := f.files[path]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	f.reads++
+	json.NewEncoder(w).Encode(map[string]string{
+		"content":        base64.StdEncoding.EncodeToString([]byte(content)),
+		"sha":            f.version(path, false),
+		"last_commit_id": f.version(path, true),
+	})
+}
+
+// fakeGithub serves the files of a repository with the contents and the trees api of GitHub.
+type fakeGithub struct{ fakeForge }
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/repos/user/repo")
+	if path == r.URL.Path || r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(401)
+		return
+	}
+	var data struct {
+		Content string `json:"content"`
+		Sha     string `json:"sha"`
+	}
+	json.NewDecoder(r.Body).Decode(&data)
+	content, _ := base64.StdEncoding.DecodeString(data.Content)
+	filePath := strings.TrimPrefix(path, "/contents/")
+	isFile := filePath != path
+	_, exists := f.files[filePath]
+
+	switch {
+	case r.Method == http.MethodGet && path == "/git/trees/HEAD" && r.URL.Query().Get("recursive") == "1":
+		tree := []map[string]string{}
+		for _, filePath := range f.paths("") {
+			tree = append(tree, map[string]string{"path": filePath, "type": "blob", "sha": f.version(filePath, false)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tree": tree, "truncated": false})
+	case r.Method == http.MethodGet && isFile:
+		f.encodeFile(w, filePath, false)
+	case r.Method == http.MethodPut && isFile && data.Sha == "" && exists:
+		w.WriteHeader(422)
+		w.Write([]byte(`{"message":"Invalid request.\n\n\"sha\" wasn't supplied."}`))
+	case (r.Method == http.MethodPut || r.Method == http.MethodDelete) && isFile && data.Sha != "" && data.Sha != f.version(filePath, false):
+		w.WriteHeader(409)
+		w.Write([]byte(`{"message":"` + filePath + ` does not match ` + data.Sha + `"}`))
+	case r.Method == http.MethodPut && isFile:
+		f.write(filePath, string(content))
+		if exists {
+			w.WriteHeader(200)
+		} else {
+			w.WriteHeader(201)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"content": map[string]string{"sha": f.version(filePath, false)}})
+	case r.Method == http.MethodDelete && isFile && exists:
+		f.remove(filePath)
+		json.NewEncoder(w).Encode(map[string]interface{}{"content": nil})
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+type fakeGitea struct{ fakeForge }
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/repos/owner/repo")
+	if path == r.URL.Path || r.Header.Get("Authorization") != "token token" {
+		w.WriteHeader(401)
+		return
+	}
+	var data struct {
+		Content string `json:"content"`
+		Sha     string `json:"sha"`
+		Branch  string `json:"branch"`
+	}
+	json.NewDecoder(r.Body).Decode(&data)
+	content, _ := base64.StdEncoding.DecodeString(data.Content)
+	filePath := strings.TrimPrefix(path, "/contents/")
+	isFile := filePath != path
+
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		json.NewEncoder(w).Encode(map[string]string{"default_branch": "main"})
+	case r.Method == http.MethodGet && path == "/git/trees/main":
+		// a single file per page
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		paths := f.paths("")
+		tree := []map[string]string{}
+		if page >= 1 && page <= len(paths) {
+			tree = append(tree, map[string]string{"path": paths[page-1], "type": "blob", "sha": f.version(paths[page-1], false)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tree": tree, "truncated": page < len(paths)})
+	case r.Method == http.MethodGet && isFile:
+		f.encodeFile(w, filePath, false)
+	case r.Method == http.MethodPost && isFile:
+		if _, exists := f.files[filePath]; exists {
+			w.WriteHeader(422)
+			w.Write([]byte(`{"message":"repository file already exists [path: ` + filePath + `]"}`))
+			return
+		}
+		f.write(filePath, string(content))
+		w.WriteHeader(201)
+	case r.Method == http.MethodPut && isFile:
+		if _, exists := f.files[filePath]; !exists || data.Sha != f.version(filePath, false) {
+			w.WriteHeader(422)
+			w.Write([]byte(`{"message":"sha does not match [given: ` + data.Sha + `]"}`))
+			return
+		}
+		f.write(filePath, string(content))
+	case r.Method == http.MethodDelete && isFile:
+		if data.Sha != f.version(filePath, false) {
+			w.WriteHeader(409)
+			return
+		}
+		f.remove(filePath)
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+type fakeGitlab struct{ fakeForge }
+
+func (f *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/group%2Fwebsite")
+	if path == r.URL.EscapedPath() || r.Header.Get("Private-Token") != "token" {
+		w.WriteHeader(401)
+		return
+	}
+	var data struct {
+		Content      string `json:"content"`
+		LastCommitId string `json:"last_commit_id"`
+		Branch       string `json:"branch"`
+	}
+	json.NewDecoder(r.Body).Decode(&data)
+	content, _ := base64.StdEncoding.DecodeString(data.Content)
+	filePath, _ := url.PathUnescape(strings.TrimPrefix(path, "/repository/files/"))
+	isFile := strings.HasPrefix(path, "/repository/files/")
+	if r.Method != http.MethodGet && data.Branch != "main" {
+		w.WriteHeader(400)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		json.NewEncoder(w).Encode(map[string]string{"default_branch": "main"})
+	case r.Method == http.MethodGet && path == "/repository/tree":
+		// a single file per page
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		paths := f.paths(r.URL.Query().Get("path"))
+		tree := []map[string]string{}
+		if page >= 1 && page <= len(paths) {
+			tree = append(tree, map[string]string{"path": paths[page-1], "type": "blob", "id": f.version(paths[page-1], false)})
+		}
+		if page < len(paths) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		json.NewEncoder(w).Encode(tree)
+	case r.Method == http.MethodGet && isFile:
+		f.encodeFile(w, filePath, true)
+	case r.Method == http.MethodPost && isFile:
+		if _, exists := f.files[filePath]; exists {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"message":"A file with this name already exists"}`))
+			return
+		}
+		f.write(filePath, string(content))
+		w.WriteHeader(201)
+	case r.Method == http.MethodPut && isFile:
+		if data.LastCommitId != f.version(filePath, true) {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"message":"You are attempting to update a file that has changed since you started editing it."}`))
+			return
+		}
+		f.write(filePath, string(content))
+	case r.Method == http.MethodDelete && isFile:
+		f.remove(filePath)
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+// racingContents creates a file at the path of the first new file right before it is written,
+// as if another client had created a post at the same path concurrently.
+type racingContents struct {
+	repoContents
+	raced string
+}
+
+func (r *racingContents) putFile(filePath string, content []byte, sha, branch, message string) error {
+	if sha == "" && r.raced == "" {
+		r.raced = filePath
+		if err := r.repoContents.putFile(filePath, []byte("Concurrent"), "", branch, "concurrent create"); err != nil {
+			return err
+		}
+	}
+	return r.repoContents.putFile(filePath, content, sha, branch, message)
+}
+
+func TestRepoStoreBackends(t *testing.T) {
+	retryDelay := repoRetryDelay
+	repoRetryDelay = time.Millisecond
+	t.Cleanup(func() { repoRetryDelay = retryDelay })
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	tests := []struct {
+		name     string
+		forge    http.Handler
+		contents func(url string, client *http.Client) repoContents
+	}{
+		{"github", &fakeGithub{fakeForge{files: map[string]string{}, commits: map[string]int{}}}, func(url string, client *http.Client) repoContents {
+			return newGithubContents(url, "token", "user", "repo", client, logger)
+		}},
+		{"gitea", &fakeGitea{fakeForge{files: map[string]string{}, commits: map[string]int{}}}, func(url string, client *http.Client) repoContents {
+			return newGiteaContents(url, "token", "owner", "repo", client, logger)
+		}},
+		{"gitlab", &fakeGitlab{fakeForge{files: map[string]string{}, commits: map[string]int{}}}, func(url string, client *http.Client) repoContents {
+			return newGitlabContents(url+"/", "token", "group/website", client, logger)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.forge)
+			defer server.Close()
+			mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+			store := newMicropubRepoStore(tt.contents(server.URL, server.Client()), "posts/", postPathTemplates{}, defaultPostFormat, false, repoCommitOptions{}, mapper, logger)
+
+			urls := make([]string, 2)
+			for i := range urls {
+				post := ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+					"content":  {fmt.Sprintf("Post %d", i)},
+					"category": {"test"},
+				}})
+				u, err := store.Create(post)
+				if err != nil {
+					t.Fatal(err)
+				}
+				urls[i] = u
+			}
+			draft := ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+				"content":     {"Draft"},
+				"category":    {"secret"},
+				"post-status": {"draft"},
+			}})
+			if _, err := store.Create(draft); err != nil {
+				t.Fatal(err)
+			}
+
+			_, version, err := store.Get(urls[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Modify(urls[0], version, nil, nil, map[string][]interface{}{"content": {"Changed"}}); err != nil {
+				t.Fatal(err)
+			}
+			err = store.Modify(urls[0], version, nil, nil, map[string][]interface{}{"content": {"Stale"}})
+			if !errors.Is(err, errConflict) {
+				t.Errorf("expected a conflict for a stale version, got %v", err)
+			}
+			// the forge rejects writes based on a stale version, or creating a file that exists
+			filePath := mapper.UrlToFilePath(urls[0])
+			if err := store.contents.putFile(filePath, []byte("Stale"), version, "", "stale"); !errors.Is(err, errConflict) {
+				t.Errorf("expected the forge to reject a stale version, got %v", err)
+			}
+			if err := store.contents.putFile(filePath, []byte("New"), "", "", "create"); !errors.Is(err, errConflict) {
+				t.Errorf("expected the forge to reject creating an existing file, got %v", err)
+			}
+			mf, _, err := store.Get(urls[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, "content", mf.Properties["content"], []interface{}{"Changed"})
+
+			// file paths are escaped in the urls of the api
+			special := "media/a b#1?.txt"
+			if err := store.contents.putFile(special, []byte("Special"), "", "", "create"); err != nil {
+				t.Fatal(err)
+			}
+			if content, _, err := store.contents.getFile(special, ""); err != nil || content != "Special" {
+				t.Errorf("expected the file with special characters to be read, got %q %v", content, err)
+			}
+			if files, err := store.contents.listFiles("media", ""); err != nil || len(files) != 1 || files[0].path != special {
+				t.Errorf("expected the file to be stored at %q, got %v %v", special, files, err)
+			}
+
+			posts, err := store.List(10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(posts) != 2 {
+				t.Errorf("expected 2 posts without the draft, got %d", len(posts))
+			}
+			categories, err := store.Categories()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, "categories", categories, []string{"test"})
+
+			if err := store.Delete(urls[1]); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := store.Get(urls[1]); !errors.Is(err, errPostNotFound) {
+				t.Errorf("expected errPostNotFound after delete, got %v", err)
+			}
+
+			// a post created concurrently at the same path moves the new post to the next free path
+			racing := &racingContents{repoContents: store.contents}
+			store.contents = racing
+			u, err := store.Create(ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+				"content": {"Raced"},
+			}}))
+			if err != nil {
+				t.Fatalf("expected the create to be retried at another path, got %v", err)
+			}
+			if racing.raced == "" || mapper.UrlToFilePath(u) == racing.raced {
+				t.Errorf("expected the post at another path than %q, got %s", racing.raced, u)
+			}
+			mf, _, err = store.Get(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, "content of the raced post", mf.Properties["content"], []interface{}{"Raced"})
+		})
+	}
+}
+
+func TestRepoStoreListing(t *testing.T) {
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	forge := &fakeGitea{fakeForge{files: map[string]string{}, commits: map[string]int{}}}
+	server := httptest.NewServer(forge)
+	defer server.Close()
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	templates, err := newPostPathTemplates("{slug}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newMicropubRepoStore(newGiteaContents(server.URL, "token", "owner", "repo", server.Client(), logger), "posts/", templates, defaultPostFormat, false, repoCommitOptions{}, mapper, logger)
+
+	// the paths are in a different order than the published dates
+	for _, post := range []struct{ slug, published string }{{"a", "2023-02-01T00:00:00Z"}, {"b", "2023-03-01T00:00:00Z"}, {"c", "2023-01-01T00:00:00Z"}} {
+		_, err := store.Create(ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+			"content":   {"Post " + post.slug},
+			"mp-slug":   {post.slug},
+			"published": {post.published},
+		}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func(limit, offset int) []interface{} {
+		posts, err := store.List(limit, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := make([]interface{}, len(posts))
+		for i, post := range posts {
+			contents[i] = post.Properties["content"][0]
+		}
+		return contents
+	}
+
+	forge.reads = 0
+	assertEqual(t, "first page", list(2, 0), []interface{}{"Post b", "Post a"})
+	assertEqual(t, "reads of the first listing", forge.reads, 3)
+	assertEqual(t, "second page", list(2, 2), []interface{}{"Post c"})
+	if _, err := store.Categories(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "reads of unchanged posts", forge.reads, 3)
+
+	if err := store.Modify("https://example.com/c", "", nil, nil, map[string][]interface{}{"published": {"2023-04-01T00:00:00Z"}}); err != nil {
+		t.Fatal(err)
+	}
+	forge.reads = 0
+	assertEqual(t, "after the update", list(1, 0), []interface{}{"Post c"})
+	assertEqual(t, "reads of the changed post", forge.reads, 1)
+}
+
+func TestRepoStoreSoftDelete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := log.New(os.Stdout, "[test] ", log.Flags())
+	forge := &fakeGitea{fakeForge{files: map[string]string{}, commits: map[string]int{}}}
+	server := httptest.NewServer(forge)
+	defer server.Close()
+	mapper := &suffixPrefixUrlMapper{urlPrefix: "https://example.com/", folder: "posts/", extension: ".md"}
+	store := newMicropubRepoStore(newGiteaContents(server.URL, "token", "owner", "repo", server.Client(), logger), "posts/", postPathTemplates{}, defaultPostFormat, true, repoCommitOptions{}, mapper, logger)
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, nil, nil, nil, stubVerifyToken, nil, logger)
+	r := gin.New()
+	if err := api.RegisterRoutes(r); err != nil {
+		t.Fatal(err)
+	}
+	do := func(req *http.Request) int {
+		req.Header.Set("Authorization", "Bearer full-token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	action := func(action, u string) int {
+		req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(url.Values{"action": {action}, "url": {u}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return do(req)
+	}
+
+	u, err := store.Create(ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+		"content":  {"Hello"},
+		"category": {"test"},
+	}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := func() int {
+		return do(httptest.NewRequest(http.MethodGet, "/micropub?"+url.Values{"q": {"source"}, "url": {u}}.Encode(), nil))
+	}
+
+	assertEqual(t, "delete status", action("delete", u), 200)
+	filePath := mapper.UrlToFilePath(u)
+	if !strings.Contains(forge.files[filePath], "deleted: true") {
+		t.Errorf("expected the post to be kept and marked as deleted, got %q", forge.files[filePath])
+	}
+	assertEqual(t, "source status of a deleted post", source(), 410)
+	update := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(fmt.Sprintf(`{"action": "update", "url": %q, "replace": {"content": ["Changed"]}}`, u)))
+	update.Header.Set("Content-Type", "application/json")
+	assertEqual(t, "update status of a deleted post", do(update), 410)
+	if posts, err := store.List(10, 0); err != nil || len(posts) != 0 {
+		t.Errorf("expected the deleted post not to be listed, got %v %v", posts, err)
+	}
+	if categories, err := store.Categories(); err != nil || len(categories) != 0 {
+		t.Errorf("expected no categories of deleted posts, got %v %v", categories, err)
+	}
+
+	assertEqual(t, "undelete status", action("undelete", u), 200)
+	assertEqual(t, "source status of a restored post", source(), 200)
+	mf, _, err := store.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "content", mf.Properties["content"], []interface{}{"Hello"})
+
+	// without soft delete the file is removed and the post is gone for good
+	store.softDelete = false
+	assertEqual(t, "hard delete status", action("delete", u), 200)
+	if _, ok := forge.files[filePath]; ok {
+		t.Errorf("expected the file to be removed")
+	}
+	assertEqual(t, "source status of a removed post", source(), 400)
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		conflict bool
+	}{
+		{409, `{"message":"posts/a.md does not match 1234"}`, true},
+		{422, `{"message":"sha does not match [given: 1234, expected: 5678]"}`, true},
+		{422, `{"message":"Update is not a fast forward"}`, true},
+		{422, `{"message":"Invalid request.\n\n\"sha\" wasn't supplied."}`, true},
+		{422, `{"message":"Invalid request.\n\n\"content\" wasn't supplied."}`, false},
+		{422, `{"message":"path contains a malformed path component"}`, false},
+		{422, `{"message":"Reference does not exist"}`, false},
+		{500, `{"message":"sha does not match"}`, false},
+	}
+	for _, tt := range tests {
+		err := statusError(tt.status, []byte(tt.body))
+		if errors.Is(err, errConflict) != tt.conflict {
+			t.Errorf("statusError(%d, %s): expected conflict %v, got %v", tt.status, tt.body, tt.conflict, err)
+		}
+	}
+	if err := statusError(404, nil); !errors.Is(err, errRepoNotFound) {
+		t.Errorf("expected errRepoNotFound for 404, got %v", err)
+	}
+}
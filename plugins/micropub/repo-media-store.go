@@ -0,0 +1,48 @@
+package micropub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+// fileStager is implemented by post stores that can commit files together with the post that uses them.
+type fileStager interface {
+	StageFile(path string, content []byte) error
+}
+
+// repoMediaStore stores media files in the repository of the post store, they are committed with the post that uses them.
+type repoMediaStore struct {
+	stager fileStager
+	// the folder in the repository the files are stored in
+	folder    string
+	urlPrefix string
+	logger    *log.Logger
+}
+
+func newRepoMediaStore(stager fileStager, folder, urlPrefix string, logger *log.Logger) *repoMediaStore {
+	return &repoMediaStore{
+		stager:    stager,
+		folder:    folder,
+		urlPrefix: urlPrefix,
+		logger:    logger,
+	}
+}
+
+func (s *repoMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	defer file.Reader.Close()
+
+	content, err := io.ReadAll(file.Reader)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %v", err)
+	}
+	name := mediaFileName(file.ContentType, s.logger)
+	if err := s.stager.StageFile(s.folder+name, content); err != nil {
+		return "", err
+	}
+
+	url := s.urlPrefix + name
+	s.logger.Printf("Staged %s as %s", file.Name, url)
+	return url, nil
+}
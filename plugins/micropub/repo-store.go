@@ -0,0 +1,402 @@
+package micropub
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"willnorris.com/go/microformats"
+)
+
+// the repository rejects writes with a stale sha, these are retried with an exponential backoff
+const repoMaxAttempts = 4
+
+var repoRetryDelay = 500 * time.Millisecond
+
+type UrlConverter interface {
+	UrlToFilePath(url string) string
+	FilePathToUrl(path string) string
+}
+
+// repoCommitOptions define where the changes of the store are committed to.
+type repoCommitOptions struct {
+	// the branch the posts are committed to, the default branch of the repository if empty
+	branch string
+	// the branch pull requests are opened against, the default branch of the repository if empty
+	baseBranch string
+	// use the git data api to write all files of a change, including staged media, in a single commit
+	gitData bool
+	// open a pull request from branch to baseBranch instead of committing to the base branch
+	pullRequest bool
+	// the directory staged media files are kept in until they are committed, see StageFile
+	stagingDir string
+}
+
+// micropubRepoStore stores the posts as files in a repository of a git forge, see repoContents.
+type micropubRepoStore struct {
+	contents repoContents
+	// set if the repository is on GitHub, required for the git data api and pull requests
+	github        *githubContents
+	folder        string
+	pathTemplates postPathTemplates
+	format        postFormat
+	softDelete    bool
+	commit        repoCommitOptions
+	urlConverter  UrlConverter
+	rand          *rand.Rand
+	logger        *log.Logger
+
+	// mu guards the fields below
+	mu sync.Mutex
+	// media files that are committed with the post that uses them, see StageFile
+	staged     []repoFile
+	stageTimer *time.Timer
+	// the parsed posts of the last listing by file path, see allPosts
+	postCache map[string]cachedPost
+}
+
+// cachedPost is a parsed post and the sha of the blob it was parsed from.
+type cachedPost struct {
+	blob string
+	post MicropubPost
+}
+
+// repoFile is a file written by a commit.
+type repoFile struct {
+	path    string
+	content []byte
+	// the version of the file that is replaced, only used by the contents api
+	sha    string
+	delete bool
+}
+
+// repoHead is the state of the repository a change is based on. Files are read at ref,
+// commit is the sha of the head commit of the branch if the git data api is used.
+type repoHead struct {
+	ref    string
+	branch string
+	commit string
+}
+
+func newMicropubRepoStore(contents repoContents, folder string, pathTemplates postPathTemplates, format postFormat, softDelete bool, commit repoCommitOptions, urlConverter UrlConverter, logger *log.Logger) *micropubRepoStore {
+	github, _ := contents.(*githubContents)
+	return &micropubRepoStore{
+		contents:      contents,
+		github:        github,
+		folder:        folder,
+		pathTemplates: pathTemplates,
+		format:        format,
+		softDelete:    softDelete,
+		commit:        commit,
+		urlConverter:  urlConverter,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:        logger,
+	}
+}
+
+func (m *micropubRepoStore) Create(post MicropubPost) (string, error) {
+	var filePath string
+	// if another post is created at the same path concurrently, the next free path is used
+	err := m.retry(func() error {
+		head, err := m.head()
+		if err != nil {
+			return err
+		}
+		path, err := nextPostPath(m.folder, m.pathTemplates.forPost(post), post, m.rand, func(path string) (bool, error) {
+			return m.exists(path, head)
+		})
+		if err != nil {
+			return err
+		}
+		filePath = path + ".md"
+		m.logger.Printf("creating post in repository: %s", filePath)
+		return m.writePost(head, filePath, post, "", "create post "+filePath)
+	})
+	if err != nil {
+		return "", err
+	}
+	return m.urlConverter.FilePathToUrl(filePath), nil
+}
+
+func (m *micropubRepoStore) Modify(u, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error {
+	filePath := m.urlConverter.UrlToFilePath(u)
+	modify := func() error {
+		head, err := m.head()
+		if err != nil {
+			return err
+		}
+		post, sha, err := m.readPost(filePath, head.ref)
+		if err != nil {
+			return err
+		}
+		if post.Deleted {
+			return errPostDeleted
+		}
+		if version != "" && sha != version {
+			return errConflict
+		}
+
+		err = ModifyEntry(&post, deleteProps, addProps, replaceProps)
+		if err != nil {
+			return err
+		}
+		return m.writePost(head, filePath, post, sha, "update post "+filePath)
+	}
+	if version != "" {
+		// the client expects the post to be unchanged, a concurrent change is a conflict
+		return modify()
+	}
+	return m.retry(modify)
+}
+
+func (m *micropubRepoStore) Delete(u string) error {
+	filePath := m.urlConverter.UrlToFilePath(u)
+	return m.retry(func() error {
+		head, err := m.head()
+		if err != nil {
+			return err
+		}
+		post, sha, err := m.readPost(filePath, head.ref)
+		if err != nil {
+			return err
+		}
+
+		if m.softDelete {
+			post.Deleted = true
+			return m.writePost(head, filePath, post, sha, "delete post "+filePath)
+		}
+		return m.writeFiles(head, "delete post "+filePath, []repoFile{{path: filePath, sha: sha, delete: true}})
+	})
+}
+
+func (m *micropubRepoStore) UnDelete(u string) error {
+	if !m.softDelete {
+		return fmt.Errorf("undelete requires soft delete to be enabled")
+	}
+	filePath := m.urlConverter.UrlToFilePath(u)
+	return m.retry(func() error {
+		head, err := m.head()
+		if err != nil {
+			return err
+		}
+		post, sha, err := m.readPost(filePath, head.ref)
+		if err != nil {
+			return err
+		}
+
+		if !post.Deleted {
+			return nil
+		}
+		post.Deleted = false
+		return m.writePost(head, filePath, post, sha, "undelete post "+filePath)
+	})
+}
+
+// Get returns the post with the sha of its file as version.
+func (m *micropubRepoStore) Get(u string) (*microformats.Microformat, string, error) {
+	head, err := m.head()
+	if err != nil {
+		return nil, "", err
+	}
+	post, sha, err := m.readPost(m.urlConverter.UrlToFilePath(u), head.ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if post.Deleted {
+		return nil, "", errPostDeleted
+	}
+	return post.Entry.ToMicroformat(), sha, nil
+}
+
+// List returns the posts newest first, see postsToMicroformats.
+func (m *micropubRepoStore) List(limit, offset int) ([]*microformats.Microformat, error) {
+	posts, err := m.allPosts()
+	if err != nil {
+		return nil, err
+	}
+	return postsToMicroformats(posts, limit, offset), nil
+}
+
+func (m *micropubRepoStore) Categories() ([]string, error) {
+	posts, err := m.allPosts()
+	if err != nil {
+		return nil, err
+	}
+	return postCategories(posts), nil
+}
+
+// allPosts returns all posts that are not deleted. Parsed posts are cached by the sha of their blob,
+// so only files that changed since the last listing are read from the repository.
+func (m *micropubRepoStore) allPosts() ([]MicropubPost, error) {
+	head, err := m.head()
+	if err != nil {
+		return nil, err
+	}
+	files, err := m.listPosts(head.ref)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	cached := m.postCache
+	m.mu.Unlock()
+	cache := make(map[string]cachedPost, len(files))
+	posts := make([]MicropubPost, 0, len(files))
+	for _, file := range files {
+		entry, ok := cached[file.path]
+		if !ok || file.blob == "" || entry.blob != file.blob {
+			post, _, err := m.readPost(file.path, head.ref)
+			if err != nil {
+				return nil, err
+			}
+			entry = cachedPost{blob: file.blob, post: post}
+		}
+		cache[file.path] = entry
+		if entry.post.Deleted {
+			continue
+		}
+		post := entry.post
+		post.Entry.Url = m.urlConverter.FilePathToUrl(file.path)
+		posts = append(posts, post)
+	}
+	// files that are no longer listed are dropped from the cache
+	m.mu.Lock()
+	m.postCache = cache
+	m.mu.Unlock()
+	return posts, nil
+}
+
+// retry calls fn until it does not return errConflict, at most repoMaxAttempts times.
+func (m *micropubRepoStore) retry(fn func() error) error {
+	delay := repoRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, errConflict) || attempt == repoMaxAttempts {
+			return err
+		}
+		m.logger.Printf("repository rejected a concurrent write, retrying in %s: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// head returns the state of the repository a change is based on. Without the git data api,
+// files are read from the branch and the contents api checks the sha of changed files.
+func (m *micropubRepoStore) head() (repoHead, error) {
+	if !m.commit.gitData && !m.commit.pullRequest {
+		return repoHead{ref: m.commit.branch, branch: m.commit.branch}, nil
+	}
+	branch, err := m.targetBranch()
+	if err != nil {
+		return repoHead{}, err
+	}
+	base := ""
+	if m.commit.pullRequest {
+		if base, err = m.baseBranch(); err != nil {
+			return repoHead{}, err
+		}
+	}
+	commit, err := m.github.branchCommit(branch, base)
+	if err != nil {
+		return repoHead{}, err
+	}
+	if m.commit.gitData {
+		// all files are read at the head commit, the branch is only updated if it has not moved since
+		return repoHead{ref: commit, branch: branch, commit: commit}, nil
+	}
+	return repoHead{ref: branch, branch: branch}, nil
+}
+
+// readPost reads and parses the post at the file path and returns it with the sha of the file.
+func (m *micropubRepoStore) readPost(filePath, ref string) (MicropubPost, string, error) {
+	content, sha, err := m.contents.getFile(filePath, ref)
+	if err != nil {
+		return MicropubPost{}, "", err
+	}
+	post, err := m.format.Parse(content)
+	if err != nil {
+		return MicropubPost{}, "", fmt.Errorf("unable to parse post %s: %w", filePath, err)
+	}
+	return post, sha, nil
+}
+
+// writePost renders the post and writes it to the file path, see writeFiles.
+func (m *micropubRepoStore) writePost(head repoHead, filePath string, post MicropubPost, sha, message string) error {
+	content, err := m.format.Render(post)
+	if err != nil {
+		return err
+	}
+	return m.writeFiles(head, message, []repoFile{{path: filePath, content: []byte(content), sha: sha}})
+}
+
+// writeFiles commits the files. With the git data api all files and the staged media used by them are written
+// in a single commit, otherwise every file is written with a request to the contents api.
+func (m *micropubRepoStore) writeFiles(head repoHead, message string, files []repoFile) error {
+	var err error
+	if m.commit.gitData {
+		staged := m.takeStaged(files)
+		err = m.github.commitFiles(head.branch, head.commit, message, append(staged, files...))
+		if err != nil {
+			// the media is committed with the next change
+			m.restage(staged)
+		} else {
+			m.unstage(staged)
+		}
+	} else {
+		for _, file := range files {
+			if file.delete {
+				err = m.contents.deleteFile(file.path, file.sha, head.branch, message)
+			} else {
+				err = m.contents.putFile(file.path, file.content, file.sha, head.branch, message)
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if m.commit.pullRequest {
+		base, err := m.baseBranch()
+		if err == nil {
+			err = m.github.ensurePullRequest(head.branch, base)
+		}
+		if err != nil {
+			// the change is committed, the pull request can be opened manually
+			m.logger.Printf("unable to open pull request for %s: %v", head.branch, err)
+		}
+	}
+	return nil
+}
+
+// exists reports whether a post with the path (without extension) exists in the repository.
+func (m *micropubRepoStore) exists(filePath string, head repoHead) (bool, error) {
+	_, _, err := m.contents.getFile(filePath+".md", head.ref)
+	if errors.Is(err, errPostNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// listPosts returns all markdown files in the folder of the store at ref.
+func (m *micropubRepoStore) listPosts(ref string) ([]repoEntry, error) {
+	files, err := m.contents.listFiles(m.folder, ref)
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]repoEntry, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file.path, ".md") {
+			posts = append(posts, file)
+		}
+	}
+	return posts, nil
+}
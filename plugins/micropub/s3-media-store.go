@@ -0,0 +1,73 @@
+package micropub
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// the part size used for multipart uploads of files with an unknown size
+const s3UnknownSizePartSize = 16 * 1024 * 1024
+
+type s3MediaStore struct {
+	client *minio.Client
+	// the name of the storage bucket
+	bucketName string
+	// the prefix to use for the uploaded files
+	prefix string
+	// the canned acl of the uploaded files, e.g. public-read, an empty string uses the bucket default
+	acl string
+
+	formatUrl FormatUrl
+	logger    *log.Logger
+}
+
+func newS3MediaStore(client *minio.Client, bucketName, prefix, acl string, formatUrl FormatUrl, logger *log.Logger) *s3MediaStore {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix += "/"
+	}
+
+	return &s3MediaStore{
+		client:     client,
+		bucketName: bucketName,
+		prefix:     prefix,
+		acl:        acl,
+		formatUrl:  formatUrl,
+		logger:     logger,
+	}
+}
+
+func (s *s3MediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile) (string, error) {
+	defer file.Reader.Close()
+
+	key, name := s.uploadKey(file.ContentType)
+
+	opts := minio.PutObjectOptions{
+		ContentType: file.ContentType,
+	}
+	if s.acl != "" {
+		opts.UserMetadata = map[string]string{"x-amz-acl": s.acl}
+	}
+	size := file.Size
+	if size <= 0 {
+		size = -1
+		opts.PartSize = s3UnknownSizePartSize
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucketName, key, file.Reader, size, opts)
+	if err != nil {
+		return "", fmt.Errorf("could not upload object: %v", err)
+	}
+
+	url := s.formatUrl(name, file.ContentType, s.prefix, s.bucketName)
+	s.logger.Printf("Uploaded %s to %s\n", file.Name, url)
+
+	return url, nil
+}
+
+func (s *s3MediaStore) uploadKey(mimeType string) (string, string) {
+	name := mediaFileName(mimeType, s.logger)
+	return s.prefix + name, name
+}
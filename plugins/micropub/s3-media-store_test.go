@@ -0,0 +1,73 @@
+package micropub
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestS3MediaStore(t *testing.T) {
+	var path, contentType, acl string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(400)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			t.Errorf("request is not signed: %s", r.Header.Get("Authorization"))
+		}
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		acl = r.Header.Get("X-Amz-Acl")
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+	}))
+	defer server.Close()
+
+	client, err := newS3Client(server.URL, "us-east-1", "access", "secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newS3MediaStore(client, "media", "uploads", "public-read",
+		func(name, contentType, prefix, bucket string) string {
+			return "https://" + bucket + ".example.com/" + prefix + name
+		},
+		log.New(os.Stdout, "[test] ", log.Flags()),
+	)
+
+	data := []byte("not really a png")
+	url, err := store.SaveMediaFiles(context.Background(), MicropubFile{
+		Name:        "photo.png",
+		ContentType: "image/png",
+		Size:        int64(len(data)),
+		Reader:      io.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(path, "/media/uploads/") || !strings.HasSuffix(path, ".png") {
+		t.Errorf("unexpected object path %s", path)
+	}
+	if !strings.HasSuffix(url, strings.TrimPrefix(path, "/media/")) || !strings.HasPrefix(url, "https://media.example.com/uploads/") {
+		t.Errorf("url %s does not match object path %s", url, path)
+	}
+	if contentType != "image/png" {
+		t.Errorf("expected content type image/png, got %s", contentType)
+	}
+	if acl != "public-read" {
+		t.Errorf("expected acl public-read, got %s", acl)
+	}
+	// plain http uploads use the chunked streaming signature, so the body is framed
+	if !bytes.Contains(body, data) {
+		t.Errorf("unexpected object content %q", body)
+	}
+}
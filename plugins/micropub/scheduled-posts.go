@@ -0,0 +1,282 @@
+package micropub
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	_ "embed"
+)
+
+// posts published less than this duration in the future are not scheduled
+const scheduleThreshold = time.Minute
+
+//go:embed scheduled-preview.tmpl
+var scheduledPreviewSource string
+
+var scheduledPreviewTemplate = template.Must(template.New("scheduled-preview").Parse(scheduledPreviewSource))
+
+var (
+	errScheduledPostNotFound = errors.New("scheduled post not found")
+	errScheduledPostClaimed  = errors.New("scheduled post is already being published")
+)
+
+// scheduledPost is a post that is written to the store at PublishAt.
+type scheduledPost struct {
+	Id        string
+	PublishAt time.Time
+	Post      MicropubPost
+	// SyndicateTo contains the uids of the syndication targets selected by the client
+	SyndicateTo []string
+	Created     time.Time
+	// Location is the url of the post once it has been published
+	Location string
+}
+
+// postQueue persists scheduled posts until they are published.
+type postQueue interface {
+	Add(post scheduledPost) error
+	// Due returns all unclaimed posts that should be published at t, oldest first.
+	Due(t time.Time) ([]scheduledPost, error)
+	// List returns all posts that have not been published yet, oldest first.
+	List() ([]scheduledPost, error)
+	// Get returns the post, also if it has already been published.
+	Get(id string) (scheduledPost, error)
+	// Claim marks the post as being published, it returns errScheduledPostClaimed if it is already claimed.
+	Claim(id string) error
+	// Release removes the claim of a post that could not be published, so it is retried.
+	Release(id string) error
+	// Published records the url of the published post, the post is kept to redirect its preview url.
+	Published(id, location string) error
+	Remove(id string) error
+}
+
+// isScheduled returns true if the post should be held back until its published date.
+func (m *micropubApiModule) isScheduled(post MicropubPost) bool {
+	return m.queue != nil && !post.Entry.IsDraft() && post.Entry.Published.After(time.Now().Add(scheduleThreshold))
+}
+
+// publishScheduled publishes all scheduled posts that are due.
+func (m *micropubApiModule) publishScheduled() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	posts, err := m.queue.Due(time.Now())
+	if err != nil {
+		m.logger.Printf("unable to read scheduled posts: %v", err)
+		return
+	}
+	for _, post := range posts {
+		if err := m.publishQueued(post); err != nil {
+			// the post stays in the queue and is retried with the next run
+			m.logger.Printf("unable to publish scheduled post %s: %v", post.Id, err)
+		}
+	}
+}
+
+// publishScheduledNow publishes the scheduled post immediately with the current time as published date.
+func (m *micropubApiModule) publishScheduledNow(id string) error {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	post, err := m.queue.Get(id)
+	if err != nil {
+		return err
+	}
+	post.Post.Entry.Published = time.Now().UTC()
+	return m.publishQueued(post)
+}
+
+// cancelScheduled removes the post from the queue without publishing it.
+func (m *micropubApiModule) cancelScheduled(id string) error {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	return m.queue.Remove(id)
+}
+
+func (m *micropubApiModule) publishQueued(post scheduledPost) error {
+	syndicators := make([]syndicator, 0, len(post.SyndicateTo))
+	for _, uid := range post.SyndicateTo {
+		s := m.syndicatorByUid(uid)
+		if s == nil {
+			m.logger.Printf("syndication target %s of scheduled post %s no longer exists", uid, post.Id)
+			continue
+		}
+		syndicators = append(syndicators, s)
+	}
+
+	// the post is claimed before it is written to the store, so it is never published twice,
+	// even if recording the location fails afterwards
+	if err := m.queue.Claim(post.Id); err != nil {
+		return err
+	}
+	location, err := m.publishPost(post.Post, syndicators)
+	if err != nil {
+		if releaseErr := m.queue.Release(post.Id); releaseErr != nil {
+			m.logger.Printf("unable to release scheduled post %s, it is not retried: %v", post.Id, releaseErr)
+		}
+		return err
+	}
+	m.logger.Printf("published scheduled post %s at %s", post.Id, location)
+	if err := m.queue.Published(post.Id, location); err != nil {
+		return fmt.Errorf("unable to record the location of the published post %s: %w", location, err)
+	}
+	return nil
+}
+
+// scheduledUrl returns the preview url of a scheduled post.
+func (m *micropubApiModule) scheduledUrl(id string) string {
+	return m.baseUrl + "/micropub/scheduled/" + url.PathEscape(id)
+}
+
+// scheduledPreview shows a scheduled post, or redirects to the post once it has been published.
+// The id of a scheduled post is a random uuid, so the preview is only known to the client that created it.
+func (m *micropubApiModule) scheduledPreview(c *gin.Context) {
+	post, err := m.queue.Get(c.Param("id"))
+	if errors.Is(err, errScheduledPostNotFound) {
+		c.String(404, "scheduled post not found")
+		return
+	} else if err != nil {
+		m.logger.Printf("unable to read scheduled post %s: %v", c.Param("id"), err)
+		c.String(500, "internal server error")
+		return
+	}
+	if post.Location != "" {
+		c.Redirect(http.StatusFound, post.Location)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = scheduledPreviewTemplate.Execute(&buf, map[string]interface{}{
+		"Name":      post.Post.Entry.Name,
+		"Content":   post.Post.Entry.Content,
+		"PublishAt": post.PublishAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		m.logger.Printf("unable to render scheduled post %s: %v", post.Id, err)
+		c.String(500, "internal server error")
+		return
+	}
+	c.Data(200, "text/html; charset=utf-8", buf.Bytes())
+}
+
+type postQueueSQLiteStore struct {
+	db     *sql.DB
+	logger *log.Logger
+}
+
+func newPostQueueSQLiteStore(db *sql.DB, logger *log.Logger) *postQueueSQLiteStore {
+	return &postQueueSQLiteStore{db: db, logger: logger}
+}
+
+func (s *postQueueSQLiteStore) Add(post scheduledPost) error {
+	postJson, err := json.Marshal(post.Post)
+	if err != nil {
+		return fmt.Errorf("unable to encode post: %w", err)
+	}
+	syndicateJson, err := json.Marshal(post.SyndicateTo)
+	if err != nil {
+		return fmt.Errorf("unable to encode syndication targets: %w", err)
+	}
+	_, err = s.db.Exec("INSERT INTO micropub_scheduled (id, publish_at, post, syndicate_to, ts) VALUES (?, ?, ?, ?, ?)",
+		post.Id, post.PublishAt.UTC().Format(time.RFC3339), string(postJson), string(syndicateJson), post.Created.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *postQueueSQLiteStore) Due(t time.Time) ([]scheduledPost, error) {
+	return s.query("SELECT id, publish_at, post, syndicate_to, ts, location FROM micropub_scheduled WHERE publish_at <= ? AND claimed IS NULL ORDER BY publish_at, ts", t.UTC().Format(time.RFC3339))
+}
+
+func (s *postQueueSQLiteStore) List() ([]scheduledPost, error) {
+	return s.query("SELECT id, publish_at, post, syndicate_to, ts, location FROM micropub_scheduled WHERE location IS NULL ORDER BY publish_at, ts")
+}
+
+func (s *postQueueSQLiteStore) Get(id string) (scheduledPost, error) {
+	posts, err := s.query("SELECT id, publish_at, post, syndicate_to, ts, location FROM micropub_scheduled WHERE id = ?", id)
+	if err != nil {
+		return scheduledPost{}, err
+	}
+	if len(posts) == 0 {
+		return scheduledPost{}, errScheduledPostNotFound
+	}
+	return posts[0], nil
+}
+
+func (s *postQueueSQLiteStore) Claim(id string) error {
+	res, err := s.db.Exec("UPDATE micropub_scheduled SET claimed = ? WHERE id = ? AND claimed IS NULL", time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := s.Get(id); err != nil {
+			return err
+		}
+		return errScheduledPostClaimed
+	}
+	return nil
+}
+
+func (s *postQueueSQLiteStore) Release(id string) error {
+	_, err := s.db.Exec("UPDATE micropub_scheduled SET claimed = NULL WHERE id = ? AND location IS NULL", id)
+	return err
+}
+
+func (s *postQueueSQLiteStore) Published(id, location string) error {
+	_, err := s.db.Exec("UPDATE micropub_scheduled SET location = ? WHERE id = ?", location, id)
+	return err
+}
+
+func (s *postQueueSQLiteStore) Remove(id string) error {
+	// published posts are kept for the redirect of their preview url
+	res, err := s.db.Exec("DELETE FROM micropub_scheduled WHERE id = ? AND location IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errScheduledPostNotFound
+	}
+	return nil
+}
+
+func (s *postQueueSQLiteStore) query(query string, args ...interface{}) ([]scheduledPost, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]scheduledPost, 0)
+	for rows.Next() {
+		var post scheduledPost
+		var publishAt, postJson, syndicateJson, created string
+		var location sql.NullString
+		err := rows.Scan(&post.Id, &publishAt, &postJson, &syndicateJson, &created, &location)
+		if err != nil {
+			return nil, err
+		}
+		post.Location = location.String
+		if post.PublishAt, err = time.Parse(time.RFC3339, publishAt); err != nil {
+			return nil, err
+		}
+		if post.Created, err = time.Parse(time.RFC3339, created); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(postJson), &post.Post); err != nil {
+			return nil, fmt.Errorf("unable to decode scheduled post %s: %w", post.Id, err)
+		}
+		if err := json.Unmarshal([]byte(syndicateJson), &post.SyndicateTo); err != nil {
+			return nil, fmt.Errorf("unable to decode syndication targets of %s: %w", post.Id, err)
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,196 @@
+package micropub
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite"
+	"willnorris.com/go/microformats"
+)
+
+// createOnlyStore records created posts, all other operations are not supported.
+type createOnlyStore struct {
+	micropubStore
+	created []MicropubPost
+	// err is returned by the next call to Create
+	err error
+}
+
+func (s *createOnlyStore) Create(post MicropubPost) (string, error) {
+	if err := s.err; err != nil {
+		s.err = nil
+		return "", err
+	}
+	s.created = append(s.created, post)
+	return "https://example.com/posts/" + post.Entry.Name, nil
+}
+
+func newTestPostQueue(t *testing.T) *postQueueSQLiteStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, file := range []string{"00012_micropub_scheduled.sql", "00014_micropub_scheduled_state.sql"} {
+		migration, err := os.ReadFile("../../model/sqlite-migrations/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := strings.Split(strings.Split(string(migration), "-- +goose Down")[0], "-- +goose Up")[1]
+		if _, err := db.Exec(up); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newPostQueueSQLiteStore(db, log.New(os.Stdout, "[test] ", log.Flags()))
+}
+
+func TestScheduledPosts(t *testing.T) {
+	queue := newTestPostQueue(t)
+	store := &createOnlyStore{}
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, queue, nil, nil, nil, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+
+	due := MicropubPost{RawData: &microformats.Data{}}
+	due.Entry.Name = "due"
+	due.Entry.Published = time.Now().Add(-time.Minute)
+	later := MicropubPost{}
+	later.Entry.Name = "later"
+	later.Entry.Published = time.Now().Add(time.Hour)
+	if !api.isScheduled(later) {
+		t.Fatalf("expected a post in the future to be scheduled")
+	}
+	for i, post := range []MicropubPost{due, later} {
+		err := queue.Add(scheduledPost{Id: post.Entry.Name, PublishAt: post.Entry.Published, Post: post, SyndicateTo: []string{}, Created: time.Now().Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api.publishScheduled()
+	if len(store.created) != 1 || store.created[0].Entry.Name != "due" {
+		t.Fatalf("expected the due post to be published, got %v", store.created)
+	}
+	posts, err := queue.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Id != "later" {
+		t.Fatalf("expected the later post to stay scheduled, got %v", posts)
+	}
+
+	if err := api.publishScheduledNow("later"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.created) != 2 || store.created[1].Entry.Published.After(time.Now()) {
+		t.Errorf("expected the later post to be published now, got %v", store.created)
+	}
+	if err := api.cancelScheduled("later"); err != errScheduledPostNotFound {
+		t.Errorf("expected %v, got %v", errScheduledPostNotFound, err)
+	}
+}
+
+func TestScheduledPostsAreClaimedBeforePublishing(t *testing.T) {
+	queue := newTestPostQueue(t)
+	store := &createOnlyStore{err: errors.New("store unavailable")}
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, queue, nil, nil, nil, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+
+	post := MicropubPost{RawData: &microformats.Data{}}
+	post.Entry.Name = "due"
+	post.Entry.Published = time.Now().Add(-time.Minute)
+	if err := queue.Add(scheduledPost{Id: "due", PublishAt: post.Entry.Published, Post: post, SyndicateTo: []string{}, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed publish releases the claim, so the post is retried
+	api.publishScheduled()
+	if due, err := queue.Due(time.Now()); err != nil || len(due) != 1 {
+		t.Fatalf("expected the post to be retried, got %v %v", due, err)
+	}
+
+	// a claimed post is not due, even if it has not been published yet
+	if err := queue.Claim("due"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Claim("due"); !errors.Is(err, errScheduledPostClaimed) {
+		t.Errorf("expected %v, got %v", errScheduledPostClaimed, err)
+	}
+	api.publishScheduled()
+	if len(store.created) != 0 {
+		t.Fatalf("expected a claimed post not to be published, got %v", store.created)
+	}
+	if err := queue.Release("due"); err != nil {
+		t.Fatal(err)
+	}
+
+	api.publishScheduled()
+	api.publishScheduled()
+	if len(store.created) != 1 {
+		t.Fatalf("expected the post to be published once, got %v", store.created)
+	}
+	published, err := queue.Get("due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "location", published.Location, "https://example.com/posts/due")
+	if posts, _ := queue.List(); len(posts) != 0 {
+		t.Errorf("expected no scheduled posts, got %v", posts)
+	}
+}
+
+func TestScheduledPostLocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	queue := newTestPostQueue(t)
+	store := &createOnlyStore{}
+	api := newMicropubApiModule(store, nopMediaStore{}, nil, queue, nil, nil, stubVerifyToken, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+	api.baseUrl = "https://indiego.example.com"
+	r := gin.New()
+	if err := api.RegisterRoutes(r); err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	form := url.Values{
+		"h":         {"entry"},
+		"name":      {"later"},
+		"content":   {"Scheduled content"},
+		"published": {time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer full-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 202 {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "https://indiego.example.com/micropub/scheduled/") {
+		t.Fatalf("expected the preview url as location, got %q", location)
+	}
+
+	preview := strings.TrimPrefix(location, "https://indiego.example.com")
+	if w := get(preview); w.Code != 200 || !strings.Contains(w.Body.String(), "Scheduled content") {
+		t.Errorf("expected the preview of the post, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := api.publishScheduledNow(strings.TrimPrefix(preview, "/micropub/scheduled/")); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(preview); w.Code != 302 || w.Header().Get("Location") != "https://example.com/posts/later" {
+		t.Errorf("expected a redirect to the published post, got %d: %s", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("/micropub/scheduled/unknown"); w.Code != 404 {
+		t.Errorf("expected 404 for an unknown post, got %d", w.Code)
+	}
+}
@@ -0,0 +1,237 @@
+package micropub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"tiim/go-comment-api/lib/mfobjects"
+
+	"willnorris.com/go/microformats"
+)
+
+// errNoDestination is returned if a post does not match any destination of the router,
+// or if the client selected a destination that does not exist.
+var errNoDestination = errors.New("no destination for post")
+
+// Destination is a store that micropub clients can select with mp-destination.
+type Destination struct {
+	Uid  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+// destinationStore is implemented by stores that consist of multiple destinations.
+type destinationStore interface {
+	Destinations() []Destination
+}
+
+// storeRoute decides which posts are written to the store of a destination.
+// A route without conditions matches all posts.
+type storeRoute struct {
+	destination Destination
+	store       micropubStore
+	postTypes   []string
+	channels    []string
+	categories  []string
+	// properties maps property names to a value one of the values of the property must have,
+	// an empty value matches all posts with the property
+	properties map[string]string
+}
+
+// storeRouter writes new posts to the store of the first matching route. Updates, deletes and queries
+// of a post are sent to the stores in the order of the routes, until a store does not return errPostNotFound.
+type storeRouter struct {
+	routes []storeRoute
+}
+
+func newStoreRouter(routes []storeRoute) *storeRouter {
+	return &storeRouter{routes: routes}
+}
+
+func (r *storeRouter) Destinations() []Destination {
+	destinations := make([]Destination, len(r.routes))
+	for i, route := range r.routes {
+		destinations[i] = route.destination
+	}
+	return destinations
+}
+
+func (r *storeRouter) Create(post MicropubPost) (string, error) {
+	route, err := r.route(post)
+	if err != nil {
+		return "", err
+	}
+	return route.store.Create(post)
+}
+
+// route returns the route selected with mp-destination, or the first route that matches the post.
+func (r *storeRouter) route(post MicropubPost) (*storeRoute, error) {
+	properties := rawProperties(post)
+	if uid := firstString(properties["mp-destination"]); uid != "" {
+		for i := range r.routes {
+			if r.routes[i].destination.Uid == uid {
+				return &r.routes[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: unknown destination %s", errNoDestination, uid)
+	}
+
+	for i := range r.routes {
+		if r.routes[i].matches(post, properties) {
+			return &r.routes[i], nil
+		}
+	}
+	return nil, errNoDestination
+}
+
+// matches returns true if the post matches all conditions of the route. A condition
+// with multiple values matches if the post has any of the values.
+func (route *storeRoute) matches(post MicropubPost, properties map[string][]interface{}) bool {
+	if len(route.postTypes) > 0 && !containsString(route.postTypes, post.Entry.PostType()) {
+		return false
+	}
+	if len(route.channels) > 0 && !containsAny(route.channels, stringValues(properties["mp-channel"])) {
+		return false
+	}
+	if len(route.categories) > 0 && !containsAny(route.categories, post.Entry.Category) {
+		return false
+	}
+	for property, value := range route.properties {
+		values, ok := properties[property]
+		if !ok || (value != "" && !containsString(stringValues(values), value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *storeRouter) Modify(url, version string, deleteProps interface{}, addProps, replaceProps map[string][]interface{}) error {
+	return r.forPost(func(store micropubStore) error {
+		return store.Modify(url, version, deleteProps, addProps, replaceProps)
+	})
+}
+
+func (r *storeRouter) Delete(url string) error {
+	return r.forPost(func(store micropubStore) error {
+		return store.Delete(url)
+	})
+}
+
+func (r *storeRouter) UnDelete(url string) error {
+	return r.forPost(func(store micropubStore) error {
+		return store.UnDelete(url)
+	})
+}
+
+func (r *storeRouter) Get(url string) (*microformats.Microformat, string, error) {
+	var mf *microformats.Microformat
+	var version string
+	err := r.forPost(func(store micropubStore) error {
+		var err error
+		mf, version, err = store.Get(url)
+		return err
+	})
+	return mf, version, err
+}
+
+// forPost calls fn with the stores of the routes until it returns an error other than errPostNotFound.
+func (r *storeRouter) forPost(fn func(store micropubStore) error) error {
+	for _, route := range r.routes {
+		err := fn(route.store)
+		if !errors.Is(err, errPostNotFound) {
+			return err
+		}
+	}
+	return errPostNotFound
+}
+
+func (r *storeRouter) List(limit, offset int) ([]*microformats.Microformat, error) {
+	type listedPost struct {
+		mf        *microformats.Microformat
+		published int64
+	}
+	posts := make([]listedPost, 0)
+	for _, route := range r.routes {
+		// every store could contain all of the requested posts
+		mfs, err := route.store.List(limit+offset, 0)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list posts of destination %s: %w", route.destination.Uid, err)
+		}
+		for _, mf := range mfs {
+			entry := mfobjects.GetHEntry(&microformats.Data{Items: []*microformats.Microformat{mf}})
+			posts = append(posts, listedPost{mf: mf, published: entry.Published.UnixNano()})
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].published > posts[j].published
+	})
+
+	mfs := make([]*microformats.Microformat, 0, limit)
+	for i := offset; i < len(posts) && len(mfs) < limit; i++ {
+		mfs = append(mfs, posts[i].mf)
+	}
+	return mfs, nil
+}
+
+func (r *storeRouter) Categories() ([]string, error) {
+	set := make(map[string]struct{})
+	for _, route := range r.routes {
+		categories, err := route.store.Categories()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list categories of destination %s: %w", route.destination.Uid, err)
+		}
+		for _, category := range categories {
+			set[category] = struct{}{}
+		}
+	}
+	categories := make([]string, 0, len(set))
+	for category := range set {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// rawProperties returns the properties sent by the client, including the mp- properties
+// that are not part of the entry.
+func rawProperties(post MicropubPost) map[string][]interface{} {
+	if post.RawData != nil && len(post.RawData.Items) > 0 {
+		return post.RawData.Items[0].Properties
+	}
+	return post.Entry.ToMicroformat().Properties
+}
+
+func firstString(values []interface{}) string {
+	strs := stringValues(values)
+	if len(strs) == 0 {
+		return ""
+	}
+	return strs[0]
+}
+
+func stringValues(values []interface{}) []string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if containsString(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
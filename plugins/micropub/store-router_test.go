@@ -0,0 +1,87 @@
+package micropub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoreRouter(t *testing.T) {
+	notes := newMemoryStore("https://example.com/notes/")
+	photos := newMemoryStore("https://example.com/photos/")
+	travel := newMemoryStore("https://travel.example.com/")
+	router := newStoreRouter([]storeRoute{
+		{destination: Destination{Uid: "travel", Name: "Travel blog"}, store: travel, categories: []string{"travel"}},
+		{destination: Destination{Uid: "photos", Name: "Photos"}, store: photos, postTypes: []string{"photo"}},
+		{destination: Destination{Uid: "notes", Name: "Notes"}, store: notes},
+	})
+
+	tests := []struct {
+		name       string
+		properties map[string][]interface{}
+		want       string
+		wantErr    error
+	}{
+		{"note", map[string][]interface{}{"content": {"Hello"}}, "https://example.com/notes/1", nil},
+		{"photo", map[string][]interface{}{"photo": {"https://example.com/1.jpg"}}, "https://example.com/photos/1", nil},
+		{"category", map[string][]interface{}{"content": {"Zurich"}, "category": {"travel"}}, "https://travel.example.com/1", nil},
+		{"mp-destination", map[string][]interface{}{"content": {"Hi"}, "category": {"travel"}, "mp-destination": {"notes"}}, "https://example.com/notes/2", nil},
+		{"unknown mp-destination", map[string][]interface{}{"content": {"Hi"}, "mp-destination": {"other"}}, "", errNoDestination},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: tt.properties})
+			got, err := router.Create(post)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got location %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if err := router.Modify("https://example.com/photos/1", "", nil, map[string][]interface{}{"category": {"cats"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := photos.posts["https://example.com/photos/1"].Entry.Category; len(got) != 1 || got[0] != "cats" {
+		t.Errorf("expected the photo to be updated, got categories %v", got)
+	}
+	if err := router.Delete("https://example.com/missing"); !errors.Is(err, errPostNotFound) {
+		t.Errorf("expected errPostNotFound, got %v", err)
+	}
+
+	categories, err := router.Categories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "categories", categories, []string{"cats", "travel"})
+
+	photos.posts["https://example.com/photos/1"].Entry.Published = time.Now().Add(time.Hour)
+	posts, err := router.List(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 || posts[0].Properties["url"][0] != "https://example.com/photos/1" {
+		t.Errorf("expected the newest post of all destinations first, got %v", posts)
+	}
+}
+
+func TestStoreRouterRoutes(t *testing.T) {
+	route := storeRoute{channels: []string{"work"}, properties: map[string]string{"location": ""}}
+	post := ParseMicropubPost(MicropubPostRaw{PostTye: []string{"h-entry"}, Properties: map[string][]interface{}{
+		"content":    {"Meeting"},
+		"mp-channel": {"work"},
+	}})
+	if route.matches(post, rawProperties(post)) {
+		t.Errorf("expected a post without location not to match")
+	}
+	post.RawData.Items[0].Properties["location"] = []interface{}{"geo:47.3,8.5"}
+	if !route.matches(post, rawProperties(post)) {
+		t.Errorf("expected a post in the channel with a location to match")
+	}
+	post.RawData.Items[0].Properties["mp-channel"] = []interface{}{"private"}
+	if route.matches(post, rawProperties(post)) {
+		t.Errorf("expected a post in another channel not to match")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 
-	"github.com/google/uuid"
 	"storj.io/uplink"
 )
 
@@ -82,25 +81,6 @@ func (s storjMediaStore) SaveMediaFiles(ctx context.Context, file MicropubFile)
 }
 
 func (s storjMediaStore) uploadKey(mimeType string) (string, string) {
-	key := s.prefix
-	extension := "bin"
-	switch mimeType {
-	case "image/jpeg":
-		extension = "jpg"
-	case "image/png":
-		extension = "png"
-	case "image/gif":
-		extension = "gif"
-	case "image/webp":
-		extension = "webp"
-	case "image/avif":
-		extension = "avif"
-	default:
-		s.logger.Println("Unknown mime type for micropub upload: ", mimeType)
-	}
-	id := uuid.New()
-	name := id.String() + "." + extension
-	key += name
-
-	return key, name
+	name := mediaFileName(mimeType, s.logger)
+	return s.prefix + name, name
 }
@@ -0,0 +1,83 @@
+package micropub
+
+import (
+	"context"
+	"fmt"
+)
+
+type SyndicationTarget struct {
+	Uid  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+type syndicator interface {
+	Target() SyndicationTarget
+	// Syndicate publishes the post that is live at url to the target and returns the url of the syndicated copy.
+	Syndicate(ctx context.Context, url string, post MicropubPost) (string, error)
+}
+
+func (m *micropubApiModule) syndicationTargets() []SyndicationTarget {
+	targets := make([]SyndicationTarget, len(m.syndicators))
+	for i, s := range m.syndicators {
+		targets[i] = s.Target()
+	}
+	return targets
+}
+
+// selectSyndicators removes the mp-syndicate-to property from the raw post and
+// returns the syndicators for the requested target uids.
+func (m *micropubApiModule) selectSyndicators(data *MicropubPostRaw) ([]syndicator, error) {
+	uids, ok := data.Properties["mp-syndicate-to"]
+	if !ok {
+		return nil, nil
+	}
+	delete(data.Properties, "mp-syndicate-to")
+
+	selected := make([]syndicator, 0, len(uids))
+	for _, uid := range uids {
+		uidStr, ok := uid.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid syndication target: %v", uid)
+		}
+		s := m.syndicatorByUid(uidStr)
+		if s == nil {
+			return nil, fmt.Errorf("unknown syndication target: %s", uidStr)
+		}
+		selected = append(selected, s)
+	}
+	return selected, nil
+}
+
+// syndicatorByUid returns the syndicator of the target uid, or nil if there is none.
+func (m *micropubApiModule) syndicatorByUid(uid string) syndicator {
+	for _, s := range m.syndicators {
+		if s.Target().Uid == uid {
+			return s
+		}
+	}
+	return nil
+}
+
+// syndicate publishes the post to all syndicators and adds the resulting urls
+// to the syndication property of the post.
+func (m *micropubApiModule) syndicate(ctx context.Context, url string, post MicropubPost, syndicators []syndicator) {
+	urls := make([]interface{}, 0, len(syndicators))
+	for _, s := range syndicators {
+		syndicationUrl, err := s.Syndicate(ctx, url, post)
+		if err != nil {
+			m.logger.Printf("unable to syndicate %s to %s: %v", url, s.Target().Uid, err)
+			continue
+		}
+		if syndicationUrl != "" {
+			m.logger.Printf("syndicated %s to %s", url, syndicationUrl)
+			urls = append(urls, syndicationUrl)
+		}
+	}
+	if len(urls) == 0 {
+		return
+	}
+	err := m.store.Modify(url, "", nil, map[string][]interface{}{"syndication": urls}, nil)
+	if err != nil {
+		m.logger.Printf("unable to add syndication urls to %s: %v", url, err)
+	}
+}
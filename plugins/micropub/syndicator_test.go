@@ -0,0 +1,63 @@
+package micropub
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blockingSyndicator returns its syndication url once release is closed.
+type blockingSyndicator struct {
+	release chan struct{}
+}
+
+func (s *blockingSyndicator) Target() SyndicationTarget {
+	return SyndicationTarget{Uid: "https://social.example/", Name: "Social"}
+}
+
+func (s *blockingSyndicator) Syndicate(ctx context.Context, u string, post MicropubPost) (string, error) {
+	<-s.release
+	return "https://social.example/status/1", nil
+}
+
+func TestSyndicationRunsInBackground(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &conformanceServer{
+		t:      t,
+		engine: gin.New(),
+		store:  newMemoryStore("https://example.com/posts/"),
+		media:  &memoryMediaStore{files: make(map[string][]byte)},
+	}
+	target := &blockingSyndicator{release: make(chan struct{})}
+	api := newMicropubApiModule(s.store, s.media, nil, nil, []syndicator{target}, nil, stubVerifyToken, nil, log.New(os.Stdout, "[test] ", log.Flags()))
+	if err := api.RegisterRoutes(s.engine); err != nil {
+		t.Fatal(err)
+	}
+
+	// the post is created while the syndication target is still blocked
+	w := s.postForm("full-token", url.Values{"h": {"entry"}, "content": {"Hello"}, "mp-syndicate-to": {"https://social.example/"}})
+	s.created(w)
+	u := w.Header().Get("Location")
+	if syndication := s.source(u, "syndication")["syndication"]; len(syndication) != 0 {
+		t.Fatalf("expected no syndication url before the target responded, got %v", syndication)
+	}
+
+	close(target.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		syndication := s.source(u, "syndication")["syndication"]
+		if len(syndication) > 0 {
+			assertEqual(t, "syndication", syndication, []interface{}{"https://social.example/status/1"})
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the syndication url to be added to the post")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,82 @@
+package micropub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// webhookSyndicator sends the post to a http endpoint which is responsible for the actual syndication.
+type webhookSyndicator struct {
+	target  SyndicationTarget
+	url     string
+	headers map[string]string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+func newWebhookSyndicator(target SyndicationTarget, url string, headers map[string]string, client *http.Client, logger *log.Logger) *webhookSyndicator {
+	return &webhookSyndicator{
+		target:  target,
+		url:     url,
+		headers: headers,
+		client:  client,
+		logger:  logger,
+	}
+}
+
+func (s *webhookSyndicator) Target() SyndicationTarget {
+	return s.target
+}
+
+func (s *webhookSyndicator) Syndicate(ctx context.Context, url string, post MicropubPost) (string, error) {
+	mf := post.Entry.ToMicroformat()
+	mf.Properties["url"] = []interface{}{url}
+	buf, err := json.Marshal(map[string]interface{}{
+		"url":    url,
+		"target": s.target.Uid,
+		"post":   mf,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewBuffer(buf))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected status code %d: %s", res.StatusCode, string(body))
+	}
+
+	if location := res.Header.Get("Location"); location != "" {
+		return location, nil
+	}
+	var response struct {
+		Url string `json:"url"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &response); err != nil {
+			return "", fmt.Errorf("unable to parse response: %w", err)
+		}
+	}
+	return response.Url, nil
+}
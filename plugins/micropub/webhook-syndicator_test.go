@@ -0,0 +1,48 @@
+package micropub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestWebhookSyndicator(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(401)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"url": "https://social.example/status/1"}`))
+	}))
+	defer server.Close()
+
+	s := newWebhookSyndicator(
+		SyndicationTarget{Uid: "https://social.example/", Name: "Social"},
+		server.URL,
+		map[string]string{"Authorization": "Bearer secret"},
+		server.Client(),
+		log.New(os.Stdout, "[test] ", log.Flags()),
+	)
+
+	post := MicropubPost{}
+	post.Entry.Content = "Hello World"
+	url, err := s.Syndicate(context.Background(), "https://example.com/posts/1", post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://social.example/status/1" {
+		t.Errorf("expected syndication url https://social.example/status/1, got %s", url)
+	}
+	if received["url"] != "https://example.com/posts/1" || received["target"] != "https://social.example/" {
+		t.Errorf("unexpected request body: %v", received)
+	}
+}
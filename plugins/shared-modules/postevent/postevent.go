@@ -0,0 +1,30 @@
+package postevent
+
+// HandlerInterface is the name of the config interface that post event handlers are registered with.
+// Plugins that want to be notified about post changes add a Handler with config.Config.AddInterface.
+const HandlerInterface = "micropub.post-event-handler"
+
+type Kind string
+
+const (
+	Created   Kind = "created"
+	Updated   Kind = "updated"
+	Deleted   Kind = "deleted"
+	UnDeleted Kind = "undeleted"
+)
+
+// Event describes a change of a post.
+type Event struct {
+	Kind Kind
+	// Url is the public url of the post
+	Url string
+	// Targets are the urls the post refers to, e.g. the in-reply-to url and the links in the content.
+	// Targets is empty for deleted posts.
+	Targets []string
+}
+
+// Handler is notified about changed posts. OnPostEvent is called from the request that changed the post,
+// long running work should be done in the background.
+type Handler interface {
+	OnPostEvent(e Event)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"tiim/go-comment-api/config"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
 	"tiim/go-comment-api/plugins/shared-modules/trigger"
 	"time"
 )
@@ -16,9 +17,12 @@ type wmSendPlugin struct {
 	// SendIntervalMinutes is the interval in minutes at which the RSS feed
 	// gets polled for new entries.
 	// Default: 60
-	IntervalMinutes int              `json:"interval_minutes"`
-	StoreData       config.ModuleRaw `json:"store" config:"webmention.send.store"`
-	Trigger         config.ModuleRaw `json:"trigger" config:"trigger"`
+	IntervalMinutes         int              `json:"interval_minutes"`
+	StoreData               config.ModuleRaw `json:"store" config:"webmention.send.store"`
+	Trigger                 config.ModuleRaw `json:"trigger" config:"trigger"`
+	EventDelaySeconds       int              `json:"event_delay_seconds"`
+	EventCheckLive          bool             `json:"event_check_live"`
+	EventLiveTimeoutMinutes int              `json:"event_live_timeout_minutes"`
 }
 
 func init() {
@@ -30,12 +34,17 @@ func (p *wmSendPlugin) IndieGoModule() config.ModuleInfo {
 		Name: "webmention.send",
 		New:  func() config.Module { return new(wmSendPlugin) },
 		Docs: config.ConfigDocs{
-			DocString: `Webmention send module. This module periodically polls an RSS feed for new entries and sends webmentions to all URLs found in new entries.`,
+			DocString: `Webmention send module. This module periodically polls an RSS feed for new entries and sends webmentions to all URLs found in new entries.
+				Webmentions for posts created, updated or deleted with the micropub plugin are sent right away. The feed poll only skips them
+				if the link of the feed item is exactly the url of the micropub post.`,
 			Fields: map[string]string{
-				"FeedUrl":         "The URL of the RSS feed. This feed gets periodically polled for new entries. When a new entry is found, webmentions get sent to all URLs found in the entry.",
-				"IntervalMinutes": "The interval in minutes at which the RSS feed gets polled for new entries. Default: 60",
-				"StoreData":       "The store module to use for storing the last sent entry.",
-				"Trigger":         "The trigger module to use for triggering the sending of webmentions. If this is not set, the sending is only done periodically.",
+				"FeedUrl":                 "The URL of the RSS feed. This feed gets periodically polled for new entries. When a new entry is found, webmentions get sent to all URLs found in the entry. If empty, only micropub posts are handled.",
+				"IntervalMinutes":         "The interval in minutes at which the RSS feed gets polled for new entries. Default: 60",
+				"StoreData":               "The store module to use for storing the last sent entry.",
+				"Trigger":                 "The trigger module to use for triggering the sending of webmentions. If this is not set, the sending is only done periodically.",
+				"EventDelaySeconds":       "The delay in seconds before webmentions for a micropub post are sent, e.g. the time the website needs to build. Default: 0",
+				"EventCheckLive":          "If true, the url of a micropub post is checked every 30 seconds after the delay, and webmentions are only sent once the post is live (or gone for deleted posts).",
+				"EventLiveTimeoutMinutes": "The maximum time in minutes to wait for a micropub post to be live. Default: 10",
 			},
 		},
 	}
@@ -53,15 +62,15 @@ func (p *wmSendPlugin) Load(config config.GlobalConfig, _ interface{}, logger *l
 	}
 	store := storeInt.(WmSendStore)
 
-	wmModule := &wmSend{
-		store:     store,
-		rss:       p.FeedUrl,
-		client:    config.HttpClient,
-		scheduler: config.Scheduler,
-		interval:  time.Minute * time.Duration(p.IntervalMinutes),
-		logger:    logger,
+	if p.EventLiveTimeoutMinutes == 0 {
+		p.EventLiveTimeoutMinutes = 10
 	}
 
+	wmModule := newWmSend(store, config.HttpClient, p.FeedUrl, config.Scheduler, time.Minute*time.Duration(p.IntervalMinutes), logger)
+	wmModule.eventDelay = time.Second * time.Duration(p.EventDelaySeconds)
+	wmModule.checkLive = p.EventCheckLive
+	wmModule.liveTimeout = time.Minute * time.Duration(p.EventLiveTimeoutMinutes)
+
 	var trig trigger.Trigger
 	if p.Trigger.Name != "" {
 		triggerInt, err := config.Config.LoadModule(p, "Trigger", nil)
@@ -74,5 +83,8 @@ func (p *wmSendPlugin) Load(config config.GlobalConfig, _ interface{}, logger *l
 		})
 	}
 
+	var handler postevent.Handler = wmModule
+	config.Config.AddInterface(postevent.HandlerInterface, handler)
+
 	return wmModule, nil
 }
@@ -0,0 +1,68 @@
+package wmsend
+
+import (
+	"fmt"
+	"net/http"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
+	"time"
+)
+
+// the interval in which the url of a post is checked until it is live
+var liveCheckInterval = 30 * time.Second
+
+// OnPostEvent sends the webmentions of a created, updated or deleted micropub post in the background.
+func (w *wmSend) OnPostEvent(e postevent.Event) {
+	go func() {
+		err := w.sendPostEvent(e)
+		if err != nil {
+			w.logger.Printf("unable to send webmentions for %s: %v", e.Url, err)
+		}
+	}()
+}
+
+func (w *wmSend) sendPostEvent(e postevent.Event) error {
+	time.Sleep(w.eventDelay)
+	if w.checkLive {
+		err := w.waitUntilLive(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	item := FeedItem{uid: e.Url, link: e.Url, updated: &now, baseUrl: e.Url}
+	// records the item as sent, polling the feed does not send the webmentions again if the feed item
+	// links to the same url and was not updated after now
+	if _, err := w.store.IsItemUpdated(item); err != nil {
+		return fmt.Errorf("unable to record post: %w", err)
+	}
+	return w.sendWebmentionsTo(item, e.Targets)
+}
+
+// waitUntilLive checks the url of the post until it returns a success status,
+// or a not found or gone status for deleted posts.
+func (w *wmSend) waitUntilLive(e postevent.Event) error {
+	deadline := time.Now().Add(w.liveTimeout)
+	for {
+		if w.isLive(e) {
+			return nil
+		}
+		if time.Now().Add(liveCheckInterval).After(deadline) {
+			return fmt.Errorf("post is not live after %v", w.liveTimeout)
+		}
+		time.Sleep(liveCheckInterval)
+	}
+}
+
+func (w *wmSend) isLive(e postevent.Event) bool {
+	res, err := w.client.Get(e.Url)
+	if err != nil {
+		w.logger.Printf("unable to check if %s is live: %v", e.Url, err)
+		return false
+	}
+	res.Body.Close()
+	if e.Kind == postevent.Deleted {
+		return res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone
+	}
+	return res.StatusCode >= 200 && res.StatusCode <= 299
+}
@@ -19,7 +19,13 @@ type wmSend struct {
 	client    *http.Client
 	scheduler *gocron.Scheduler
 	interval  time.Duration
-	logger    *log.Logger
+	// the delay before webmentions for a post event are sent
+	eventDelay time.Duration
+	// if true, webmentions for a post event are only sent after the post url is live
+	checkLive bool
+	// the maximum time to wait for the post url to be live
+	liveTimeout time.Duration
+	logger      *log.Logger
 }
 
 type FeedItem struct {
@@ -30,13 +36,14 @@ type FeedItem struct {
 	baseUrl string
 }
 
-func newWmSend(store WmSendStore, client *http.Client, rss string, scheduler *gocron.Scheduler, interval time.Duration) *wmSend {
+func newWmSend(store WmSendStore, client *http.Client, rss string, scheduler *gocron.Scheduler, interval time.Duration, logger *log.Logger) *wmSend {
 	return &wmSend{
 		store:     store,
 		rss:       rss,
 		client:    client,
 		scheduler: scheduler,
 		interval:  interval,
+		logger:    logger,
 	}
 }
 
@@ -49,11 +56,17 @@ func (w *wmSend) Init(config config.GlobalConfig) error {
 }
 
 func (w *wmSend) Start() error {
+	if w.rss == "" {
+		return nil
+	}
 	w.scheduler.Every(w.interval).Do(w.SendNow)
 	return nil
 }
 
 func (w *wmSend) SendNow() {
+	if w.rss == "" {
+		return
+	}
 	go func() {
 		err := w.doFetchAndSend()
 		if err != nil {
@@ -122,7 +135,12 @@ func (w *wmSend) sendWebmentions(item FeedItem) error {
 	if err != nil {
 		return fmt.Errorf("unable to discover links: %w", err)
 	}
+	return w.sendWebmentionsTo(item, links)
+}
 
+// sendWebmentionsTo sends webmentions from the item to the links and to all links saved for the item before,
+// so targets that were removed from the item are notified as well.
+func (w *wmSend) sendWebmentionsTo(item FeedItem, links []string) error {
 	savedLinks, err := w.store.GetUrlsForFeedItem(item)
 
 	if err != nil {
@@ -1,11 +1,18 @@
 package wmsend
 
 import (
+	"database/sql"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"tiim/go-comment-api/plugins/shared-modules/postevent"
 	"time"
+
+	_ "modernc.org/sqlite"
 )
 
 type TestWMStore struct {
@@ -54,7 +61,7 @@ func Test_wmSend_getFeedItems(t *testing.T) {
 		return nil
 	})
 
-	wmsend := newWmSend(&TestWMStore{}, client, "https://tiim.ch/blog/rss.xml", nil, 0)
+	wmsend := newWmSend(&TestWMStore{}, client, "https://tiim.ch/blog/rss.xml", nil, 0, log.New(io.Discard, "", 0))
 	feed, err := wmsend.getFeedItems()
 
 	if err != nil {
@@ -90,7 +97,7 @@ func Test_wmSend_sendWebmentions(t *testing.T) {
 		t.Errorf("unable to open testdata: %v", err)
 	}
 
-	wmsend := newWmSend(&TestWMStore{}, client, "", nil, 0)
+	wmsend := newWmSend(&TestWMStore{}, client, "", nil, 0, log.New(io.Discard, "", 0))
 	now := time.Now()
 	item := FeedItem{uid: "123", baseUrl: "https://tiim.ch/blog/2022-09-27-sveltekit-ssr-with-urql", updated: &now, content: string(buf)}
 	err = wmsend.sendWebmentions(item)
@@ -124,7 +131,7 @@ func Test_wmSend_sendWebmentions_preexisting_urls(t *testing.T) {
 		t.Errorf("unable to open testdata: %v", err)
 	}
 
-	wmsend := newWmSend(&TestWMStore{urls: []string{"https://example.com/1", "https://kit.svelte.dev/docs/load"}}, client, "", nil, 0)
+	wmsend := newWmSend(&TestWMStore{urls: []string{"https://example.com/1", "https://kit.svelte.dev/docs/load"}}, client, "", nil, 0, log.New(io.Discard, "", 0))
 	now := time.Now()
 	item := FeedItem{uid: "123", baseUrl: "https://tiim.ch/blog/2022-09-27-sveltekit-ssr-with-urql", updated: &now, content: string(buf)}
 	err = wmsend.sendWebmentions(item)
@@ -138,3 +145,107 @@ func Test_wmSend_sendWebmentions_preexisting_urls(t *testing.T) {
 		return
 	}
 }
+
+func Test_wmSend_sendPostEvent(t *testing.T) {
+	liveCheckInterval = time.Millisecond
+	postChecks := 0
+	var mentioned []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		switch req.URL.String() {
+		case "https://example.com/posts/1":
+			postChecks++
+			if postChecks < 3 {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader(""))}
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}
+		case "https://other.example/note":
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Link": []string{`</webmention>; rel="webmention"`}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+				Request:    req,
+			}
+		case "https://other.example/webmention":
+			req.ParseForm()
+			mentioned = append(mentioned, req.PostForm.Get("source")+" -> "+req.PostForm.Get("target"))
+			return &http.Response{StatusCode: 202, Body: io.NopCloser(strings.NewReader(""))}
+		}
+		t.Errorf("unexpected request: %v", req.URL.String())
+		return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader(""))}
+	})
+
+	wmsend := newWmSend(&TestWMStore{}, client, "", nil, 0, log.New(io.Discard, "", 0))
+	wmsend.checkLive = true
+	wmsend.liveTimeout = time.Minute
+	err := wmsend.sendPostEvent(postevent.Event{Kind: postevent.Created, Url: "https://example.com/posts/1", Targets: []string{"https://other.example/note"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if postChecks != 3 {
+		t.Errorf("expected 3 liveness checks, got %d", postChecks)
+	}
+	if len(mentioned) != 1 || mentioned[0] != "https://example.com/posts/1 -> https://other.example/note" {
+		t.Errorf("unexpected webmentions %v", mentioned)
+	}
+}
+
+func newTestSQLiteStore(t *testing.T) *wmSendSqliteStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, file := range []string{"00007_wm_send_table.sql", "00008_wm_url_remove_id.sql"} {
+		migration, err := os.ReadFile("../../model/sqlite-migrations/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := strings.Split(strings.Split(string(migration), "-- +goose Down")[0], "-- +goose Up")[1]
+		if _, err := db.Exec(up); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newWmSendStore(db, log.New(io.Discard, "", 0))
+}
+
+func Test_wmSend_sendPostEvent_then_feed(t *testing.T) {
+	var requests []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		requests = append(requests, req.Method+" "+req.URL.String())
+		switch req.URL.String() {
+		case "https://tiim.ch/blog/rss.xml":
+			f, err := os.Open("testdata/rss/tiim.ch.rss.xml")
+			if err != nil {
+				t.Errorf("unable to open testdata: %v", err)
+			}
+			return &http.Response{StatusCode: 200, Body: f, Header: http.Header{"Content-Type": []string{"application/rss+xml"}}}
+		case "https://other.example/note":
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Link": []string{`</webmention>; rel="webmention"`}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+				Request:    req,
+			}
+		}
+		return &http.Response{StatusCode: 202, Body: io.NopCloser(strings.NewReader(""))}
+	})
+
+	wmsend := newWmSend(newTestSQLiteStore(t), client, "https://tiim.ch/blog/rss.xml", nil, 0, log.New(io.Discard, "", 0))
+	// the micropub url of the post is the link of the feed item
+	err := wmsend.sendPostEvent(postevent.Event{Kind: postevent.Created, Url: "https://tiim.ch/blog/2022-09-27-sveltekit-ssr-with-urql", Targets: []string{"https://other.example/note"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[1] != "POST https://other.example/webmention" {
+		t.Fatalf("expected a webmention for the post event, got %v", requests)
+	}
+
+	requests = nil
+	if err := wmsend.doFetchAndSend(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("expected the feed poll to only read the feed, got %v", requests)
+	}
+}